@@ -2,16 +2,50 @@
 package traefik_power_management
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"hash"
 	"html/template"
+	"io"
+	"math/big"
+	mathrand "math/rand"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
 	"net/url"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	texttemplate "text/template"
 	"time"
 )
 
@@ -27,37 +61,185 @@ const (
 	
 	// DefaultRetryAttempts is the default number of wake retry attempts
 	DefaultRetryAttempts = 3
+	
+	// defaultUserAgent identifies the plugin's health checks unless healthCheckUserAgent overrides it
+	defaultUserAgent = "Traefik-WOL-Plugin/" + PluginVersion
+	
+	// sseKeepAliveInterval is how often an idle status stream sends a keep-alive comment
+	sseKeepAliveInterval = 15 * time.Second
+	
+	// ipv6AllNodesMulticast is the link-local all-nodes multicast group used for IPv6 WOL
+	ipv6AllNodesMulticast = "ff02::1"
+	
+	// scheduleCheckInterval is how often scheduled awake windows are evaluated
+	scheduleCheckInterval = time.Minute
 )
 
 // Config holds the plugin configuration.
 type Config struct {
 	HealthCheck         string `json:"healthCheck,omitempty" yaml:"healthCheck,omitempty"`
+	HealthCheckPath     string `json:"healthCheckPath,omitempty" yaml:"healthCheckPath,omitempty"`
+	ServiceURL          string `json:"serviceURL,omitempty" yaml:"serviceURL,omitempty"`
+	HealthChecks        []string `json:"healthChecks,omitempty" yaml:"healthChecks,omitempty"`
+	HealthCheckMode     string `json:"healthCheckMode,omitempty" yaml:"healthCheckMode,omitempty"`
+	HealthCheckType     string `json:"healthCheckType,omitempty" yaml:"healthCheckType,omitempty"`
 	MacAddress          string `json:"macAddress,omitempty" yaml:"macAddress,omitempty"`
 	IPAddress           string `json:"ipAddress,omitempty" yaml:"ipAddress,omitempty"`
+	WakeTargetResolveTTL string `json:"wakeTargetResolveTTL,omitempty" yaml:"wakeTargetResolveTTL,omitempty"`
 	BroadcastAddress    string `json:"broadcastAddress,omitempty" yaml:"broadcastAddress,omitempty"`
+	BroadcastAddresses  []string `json:"broadcastAddresses,omitempty" yaml:"broadcastAddresses,omitempty"`
 	NetworkInterface    string `json:"networkInterface,omitempty" yaml:"networkInterface,omitempty"`
+	EnableIPv6          bool   `json:"enableIPv6,omitempty" yaml:"enableIPv6,omitempty"`
+	AllowedSubnets      []string `json:"allowedSubnets,omitempty" yaml:"allowedSubnets,omitempty"`
+	WakeTargetOrder     []string `json:"wakeTargetOrder,omitempty" yaml:"wakeTargetOrder,omitempty"`
+	StopOnFirstSuccess  bool     `json:"stopOnFirstSuccess,omitempty" yaml:"stopOnFirstSuccess,omitempty"`
+	ParallelWakeSends   bool     `json:"parallelWakeSends,omitempty" yaml:"parallelWakeSends,omitempty"`
+	ReuseWakeConnections bool    `json:"reuseWakeConnections,omitempty" yaml:"reuseWakeConnections,omitempty"`
+	WakeTransport       string   `json:"wakeTransport,omitempty" yaml:"wakeTransport,omitempty"`
+	WakeMethod          string            `json:"wakeMethod,omitempty" yaml:"wakeMethod,omitempty"`
+	GatewayURL          string            `json:"gatewayURL,omitempty" yaml:"gatewayURL,omitempty"`
+	GatewayToken        string            `json:"gatewayToken,omitempty" yaml:"gatewayToken,omitempty"`
+	GatewayHeaders      map[string]string `json:"gatewayHeaders,omitempty" yaml:"gatewayHeaders,omitempty"`
+	GatewayBody         string            `json:"gatewayBody,omitempty" yaml:"gatewayBody,omitempty"`
+	GatewayTimeout      string            `json:"gatewayTimeout,omitempty" yaml:"gatewayTimeout,omitempty"`
 	Port                string `json:"port,omitempty" yaml:"port,omitempty"`
+	SourcePort          string `json:"sourcePort,omitempty" yaml:"sourcePort,omitempty"`
 	Timeout             string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
 	RetryAttempts       string `json:"retryAttempts,omitempty" yaml:"retryAttempts,omitempty"`
 	RetryInterval       string `json:"retryInterval,omitempty" yaml:"retryInterval,omitempty"`
+	RetryBackoff        string `json:"retryBackoff,omitempty" yaml:"retryBackoff,omitempty"`
+	RetryMaxInterval    string `json:"retryMaxInterval,omitempty" yaml:"retryMaxInterval,omitempty"`
+	WakePollInterval    string `json:"wakePollInterval,omitempty" yaml:"wakePollInterval,omitempty"`
+	WakePollMaxInterval string `json:"wakePollMaxInterval,omitempty" yaml:"wakePollMaxInterval,omitempty"`
+	WakeHealthyThreshold string `json:"wakeHealthyThreshold,omitempty" yaml:"wakeHealthyThreshold,omitempty"`
+	AutoWakeMode        string `json:"autoWakeMode,omitempty" yaml:"autoWakeMode,omitempty"`
+	AutoWakeRetryAfter  string `json:"autoWakeRetryAfter,omitempty" yaml:"autoWakeRetryAfter,omitempty"`
+	MaxClientWait       string `json:"maxClientWait,omitempty" yaml:"maxClientWait,omitempty"`
+	ColdResponseBody    string `json:"coldResponseBody,omitempty" yaml:"coldResponseBody,omitempty"`
+	ColdResponseContentType string `json:"coldResponseContentType,omitempty" yaml:"coldResponseContentType,omitempty"`
+	WakeOnMethods       []string `json:"wakeOnMethods,omitempty" yaml:"wakeOnMethods,omitempty"`
+	NonWakeMethodStatus string   `json:"nonWakeMethodStatus,omitempty" yaml:"nonWakeMethodStatus,omitempty"`
+	FallbackURL         string `json:"fallbackURL,omitempty" yaml:"fallbackURL,omitempty"`
+	PreWakeWebhookURL      string `json:"preWakeWebhookURL,omitempty" yaml:"preWakeWebhookURL,omitempty"`
+	PreWakeWebhookTimeout  string `json:"preWakeWebhookTimeout,omitempty" yaml:"preWakeWebhookTimeout,omitempty"`
+	PreWakeWebhookAttempts string `json:"preWakeWebhookAttempts,omitempty" yaml:"preWakeWebhookAttempts,omitempty"`
+	ProgressSendPct     string `json:"progressSendPct,omitempty" yaml:"progressSendPct,omitempty"`
+	ProgressWaitPct     string `json:"progressWaitPct,omitempty" yaml:"progressWaitPct,omitempty"`
 	HealthCheckInterval string `json:"healthCheckInterval,omitempty" yaml:"healthCheckInterval,omitempty"`
+	HealthCheckJitter   string `json:"healthCheckJitter,omitempty" yaml:"healthCheckJitter,omitempty"`
+	HealthFlapThreshold string `json:"healthFlapThreshold,omitempty" yaml:"healthFlapThreshold,omitempty"`
+	StatusMaxStaleness  string `json:"statusMaxStaleness,omitempty" yaml:"statusMaxStaleness,omitempty"`
+	WarmHealthCacheOnStart bool `json:"warmHealthCacheOnStart,omitempty" yaml:"warmHealthCacheOnStart,omitempty"`
+	StartupGracePeriod  string `json:"startupGracePeriod,omitempty" yaml:"startupGracePeriod,omitempty"`
+	HealthCheckMethod          string            `json:"healthCheckMethod,omitempty" yaml:"healthCheckMethod,omitempty"`
+	HealthCheckBody            string            `json:"healthCheckBody,omitempty" yaml:"healthCheckBody,omitempty"`
+	HealthCheckHeaders         map[string]string `json:"healthCheckHeaders,omitempty" yaml:"healthCheckHeaders,omitempty"`
+	ForwardHealthAuthFromRequest bool     `json:"forwardHealthAuthFromRequest,omitempty" yaml:"forwardHealthAuthFromRequest,omitempty"`
+	ForwardHealthAuthHeaders     []string `json:"forwardHealthAuthHeaders,omitempty" yaml:"forwardHealthAuthHeaders,omitempty"`
+	HealthCheckFollowRedirects bool `json:"healthCheckFollowRedirects,omitempty" yaml:"healthCheckFollowRedirects,omitempty"`
+	HealthCheckMaxRedirects    string `json:"healthCheckMaxRedirects,omitempty" yaml:"healthCheckMaxRedirects,omitempty"`
+	HealthCheckClientCert      string `json:"healthCheckClientCert,omitempty" yaml:"healthCheckClientCert,omitempty"`
+	HealthCheckClientKey       string `json:"healthCheckClientKey,omitempty" yaml:"healthCheckClientKey,omitempty"`
+	HealthCheckGRPCService     string `json:"healthCheckGrpcService,omitempty" yaml:"healthCheckGrpcService,omitempty"`
+	ReadinessCheck             string `json:"readinessCheck,omitempty" yaml:"readinessCheck,omitempty"`
+	HealthCheckUserAgent       string `json:"healthCheckUserAgent,omitempty" yaml:"healthCheckUserAgent,omitempty"`
+	HealthCheckDisableKeepAlive bool `json:"healthCheckDisableKeepAlive,omitempty" yaml:"healthCheckDisableKeepAlive,omitempty"`
+	HealthCheckProxyURL        string `json:"healthCheckProxyUrl,omitempty" yaml:"healthCheckProxyUrl,omitempty"`
+	HealthCheckExpectBody      string `json:"healthCheckExpectBody,omitempty" yaml:"healthCheckExpectBody,omitempty"`
+	HealthCheckExpectBodyRegex string `json:"healthCheckExpectBodyRegex,omitempty" yaml:"healthCheckExpectBodyRegex,omitempty"`
+	HealthCheckMaxLatency      string `json:"healthCheckMaxLatency,omitempty" yaml:"healthCheckMaxLatency,omitempty"`
+	MaxHealthBodyBytes         string `json:"maxHealthBodyBytes,omitempty" yaml:"maxHealthBodyBytes,omitempty"`
+	PacketRepeat        string `json:"packetRepeat,omitempty" yaml:"packetRepeat,omitempty"`
+	PacketRepeatDelay   string `json:"packetRepeatDelay,omitempty" yaml:"packetRepeatDelay,omitempty"`
+	PacketFormat        string `json:"packetFormat,omitempty" yaml:"packetFormat,omitempty"`
+	SecureOnPassword    string `json:"secureOnPassword,omitempty" yaml:"secureOnPassword,omitempty"`
 	Debug               bool   `json:"debug,omitempty" yaml:"debug,omitempty"`
+	QuietWhenHealthy    bool   `json:"quietWhenHealthy,omitempty" yaml:"quietWhenHealthy,omitempty"`
+	DryRun              bool   `json:"dryRun,omitempty" yaml:"dryRun,omitempty"`
+	MaintenanceMode     bool   `json:"maintenanceMode,omitempty" yaml:"maintenanceMode,omitempty"`
+	MaintenanceMessage  string `json:"maintenanceMessage,omitempty" yaml:"maintenanceMessage,omitempty"`
 	EnableControlPage   bool   `json:"enableControlPage,omitempty" yaml:"enableControlPage,omitempty"`
 	ControlPageTitle    string `json:"controlPageTitle,omitempty" yaml:"controlPageTitle,omitempty"`
 	ServiceDescription  string `json:"serviceDescription,omitempty" yaml:"serviceDescription,omitempty"`
+	ControlPageTemplatePath   string `json:"controlPageTemplatePath,omitempty" yaml:"controlPageTemplatePath,omitempty"`
+	ControlPageTemplateInline string `json:"controlPageTemplateInline,omitempty" yaml:"controlPageTemplateInline,omitempty"`
+	ControlPageCustomCSS      string `json:"controlPageCustomCSS,omitempty" yaml:"controlPageCustomCSS,omitempty"`
+	ControlPageLogoURL        string `json:"controlPageLogoURL,omitempty" yaml:"controlPageLogoURL,omitempty"`
+	ControlPageSecurityHeaders bool  `json:"controlPageSecurityHeaders,omitempty" yaml:"controlPageSecurityHeaders,omitempty"`
+	ControlPageCSP            string `json:"controlPageCSP,omitempty" yaml:"controlPageCSP,omitempty"`
+	Language                  string `json:"language,omitempty" yaml:"language,omitempty"`
+	StatusPollIntervalMs      string `json:"statusPollIntervalMs,omitempty" yaml:"statusPollIntervalMs,omitempty"`
+	StatusPollMaxIntervalMs   string `json:"statusPollMaxIntervalMs,omitempty" yaml:"statusPollMaxIntervalMs,omitempty"`
+	StatusPollBackoff         string `json:"statusPollBackoff,omitempty" yaml:"statusPollBackoff,omitempty"`
 	
 	// Auto-redirect configuration
 	AutoRedirect            bool   `json:"autoRedirect,omitempty" yaml:"autoRedirect,omitempty"`
 	RedirectDelay           string `json:"redirectDelay,omitempty" yaml:"redirectDelay,omitempty"`
+	AutoRedirectRequireStableHealthy string `json:"autoRedirectRequireStableHealthy,omitempty" yaml:"autoRedirectRequireStableHealthy,omitempty"`
+	RedirectTarget          string `json:"redirectTarget,omitempty" yaml:"redirectTarget,omitempty"`
+	RedirectStatusCode      string `json:"redirectStatusCode,omitempty" yaml:"redirectStatusCode,omitempty"`
+	BypassSessionDuration   string `json:"bypassSessionDuration,omitempty" yaml:"bypassSessionDuration,omitempty"`
 	SkipControlPageWhenHealthy bool   `json:"skipControlPageWhenHealthy,omitempty" yaml:"skipControlPageWhenHealthy,omitempty"`
+	ContentNegotiation      bool   `json:"contentNegotiation,omitempty" yaml:"contentNegotiation,omitempty"`
 	
 	// Dashboard configuration
 	ShowPowerOffButton  bool   `json:"showPowerOffButton,omitempty" yaml:"showPowerOffButton,omitempty"`
 	ConfirmPowerOff     bool   `json:"confirmPowerOff,omitempty" yaml:"confirmPowerOff,omitempty"`
+	PowerOffConfirmMessage string `json:"powerOffConfirmMessage,omitempty" yaml:"powerOffConfirmMessage,omitempty"`
+	PowerOffRequireTyping  bool   `json:"powerOffRequireTyping,omitempty" yaml:"powerOffRequireTyping,omitempty"`
 	HideRedirectButton  bool   `json:"hideRedirectButton,omitempty" yaml:"hideRedirectButton,omitempty"`
+	ShowTargetDetails   bool   `json:"showTargetDetails,omitempty" yaml:"showTargetDetails,omitempty"`
+	EnableCSRFProtection bool   `json:"enableCSRFProtection,omitempty" yaml:"enableCSRFProtection,omitempty"`
+	AllowedControlIPs   []string `json:"allowedControlIPs,omitempty" yaml:"allowedControlIPs,omitempty"`
+	ReadOnlyControlIPs  []string `json:"readOnlyControlIPs,omitempty" yaml:"readOnlyControlIPs,omitempty"`
+	ControlRoleHeader   string   `json:"controlRoleHeader,omitempty" yaml:"controlRoleHeader,omitempty"`
+	ReadOnlyRoles       []string `json:"readOnlyRoles,omitempty" yaml:"readOnlyRoles,omitempty"`
+	TrustForwardedFor   bool     `json:"trustForwardedFor,omitempty" yaml:"trustForwardedFor,omitempty"`
+	AdminToken          string   `json:"adminToken,omitempty" yaml:"adminToken,omitempty"`
 	
 	// Power-off configuration
+	PowerOffMethod      string `json:"powerOffMethod,omitempty" yaml:"powerOffMethod,omitempty"`
 	PowerOffCommand     string `json:"powerOffCommand,omitempty" yaml:"powerOffCommand,omitempty"`
+	SSHHost             string `json:"sshHost,omitempty" yaml:"sshHost,omitempty"`
+	SSHUser             string `json:"sshUser,omitempty" yaml:"sshUser,omitempty"`
+	SSHKey              string `json:"sshKey,omitempty" yaml:"sshKey,omitempty"`
+	SSHHostKey          string `json:"sshHostKey,omitempty" yaml:"sshHostKey,omitempty"`
+	SSHCommand          string `json:"sshCommand,omitempty" yaml:"sshCommand,omitempty"`
+	PowerOffDrainPeriod string `json:"powerOffDrainPeriod,omitempty" yaml:"powerOffDrainPeriod,omitempty"`
+	PowerOffTimeout     string `json:"powerOffTimeout,omitempty" yaml:"powerOffTimeout,omitempty"`
+	ShutdownCheck       string `json:"shutdownCheck,omitempty" yaml:"shutdownCheck,omitempty"`
+	
+	// Idle shutdown configuration
+	IdleShutdownTimeout string `json:"idleShutdownTimeout,omitempty" yaml:"idleShutdownTimeout,omitempty"`
+	
+	// Keep-alive configuration
+	KeepAliveInterval    string `json:"keepAliveInterval,omitempty" yaml:"keepAliveInterval,omitempty"`
+	KeepAliveURL         string `json:"keepAliveURL,omitempty" yaml:"keepAliveURL,omitempty"`
+	KeepAliveIdleTimeout string `json:"keepAliveIdleTimeout,omitempty" yaml:"keepAliveIdleTimeout,omitempty"`
+	
+	// Scheduled awake windows, e.g. "Mon-Fri 08:00-18:00"
+	Schedule            []string `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	ScheduleTimezone    string   `json:"scheduleTimezone,omitempty" yaml:"scheduleTimezone,omitempty"`
+	
+	// MQTT state publishing configuration
+	MQTTBroker          string `json:"mqttBroker,omitempty" yaml:"mqttBroker,omitempty"`
+	MQTTTopic           string `json:"mqttTopic,omitempty" yaml:"mqttTopic,omitempty"`
+	MQTTClientID        string `json:"mqttClientId,omitempty" yaml:"mqttClientId,omitempty"`
+	MQTTUsername        string `json:"mqttUsername,omitempty" yaml:"mqttUsername,omitempty"`
+	MQTTPassword        string `json:"mqttPassword,omitempty" yaml:"mqttPassword,omitempty"`
+	
+	// Audit log configuration
+	AuditLogPath        string `json:"auditLogPath,omitempty" yaml:"auditLogPath,omitempty"`
+	
+	// Tracing configuration
+	EnableTracing       bool   `json:"enableTracing,omitempty" yaml:"enableTracing,omitempty"`
+	TracingEndpoint     string `json:"tracingEndpoint,omitempty" yaml:"tracingEndpoint,omitempty"`
+	
+	// Circuit breaker configuration
+	CircuitBreakerThreshold string `json:"circuitBreakerThreshold,omitempty" yaml:"circuitBreakerThreshold,omitempty"`
+	CircuitBreakerWindow    string `json:"circuitBreakerWindow,omitempty" yaml:"circuitBreakerWindow,omitempty"`
+	CircuitBreakerCooldown  string `json:"circuitBreakerCooldown,omitempty" yaml:"circuitBreakerCooldown,omitempty"`
+	MaxConcurrentOperations string `json:"maxConcurrentOperations,omitempty" yaml:"maxConcurrentOperations,omitempty"`
 }
 
 // CreateConfig creates the default plugin configuration.
@@ -68,6 +250,10 @@ func CreateConfig() *Config {
 		RetryAttempts:       fmt.Sprintf("%d", DefaultRetryAttempts),
 		RetryInterval:       "5",
 		HealthCheckInterval: "10",
+		HealthCheckMethod:          http.MethodGet,
+		HealthCheckFollowRedirects: true,
+		PacketRepeat:        "1",
+		PacketRepeatDelay:   "0",
 		Debug:               false,
 		EnableControlPage:   false,
 		ControlPageTitle:    "Service Control",
@@ -82,6 +268,8 @@ func CreateConfig() *Config {
 		ShowPowerOffButton:  true,
 		ConfirmPowerOff:     true,
 		HideRedirectButton:  false,
+		EnableCSRFProtection: true,
+		ControlPageSecurityHeaders: true,
 		
 		// Power-off defaults
 		PowerOffCommand:     "/usr/local/bin/shutdown-script.sh",
@@ -90,9 +278,24 @@ func CreateConfig() *Config {
 
 // healthStatus holds cached health check results
 type healthStatus struct {
-	isHealthy  bool
-	lastCheck  time.Time
-	lastState  bool
+	isHealthy     bool
+	lastCheck     time.Time
+	lastState     bool
+	interval      time.Duration // effective cache lifetime of this result, including jitter
+	flapCount     int           // consecutive checks disagreeing with isHealthy, see healthFlapThreshold
+	healthyProbes int           // consecutive probes that came back healthy, before flap smoothing
+}
+
+// availabilityStats accumulates how long the service has spent healthy and unhealthy, from the first health
+// result onward, for /_wol/stats
+type availabilityStats struct {
+	observedSince  time.Time // first recorded health result; zero until then
+	isHealthy      bool
+	since          time.Time // start of the current healthy or unhealthy period
+	healthyTotal   time.Duration // closed healthy periods; the open one is added when reporting
+	unhealthyTotal time.Duration
+	outages        int       // healthy to unhealthy transitions
+	lastTransition time.Time // zero until the state first changes
 }
 
 // wakeStatus tracks the current wake/power operations
@@ -102,6 +305,11 @@ type wakeStatus struct {
 	startTime     time.Time
 	message       string
 	progress      int // 0-100
+	// lastError describes the most recent failed wake attempt until a wake succeeds
+	lastError       string
+	lastFailureTime time.Time
+	// broadcastFallback explains why the last send fell back to the limited broadcast; empty when it didn't
+	broadcastFallback string
 }
 
 // bypassStatus tracks bypass state for "Go to Service" functionality
@@ -114,1270 +322,9418 @@ type bypassStatus struct {
 type WOLPlugin struct {
 	next                http.Handler
 	name                string
-	healthCheck         string
+	healthChecks        []string
+	healthCheckMode     string
+	healthCheckType     string
+	arpTablePath        string
 	macAddress          string
 	ipAddress           string
-	broadcastAddress    string
+	wakeTargetResolveTTL time.Duration // how long a resolved wake target hostname is reused; zero resolves every send
+	broadcastAddresses  []string // configured broadcast targets; empty means auto-discover
 	networkInterface    string
+	wakeTransport       string
+	wakeMethod          string // "local" sends the magic packet itself, "gateway" asks gatewayURL to, "both" does both
+	gatewayURL          string
+	gatewayToken        string
+	gatewayHeaders      map[string]string
+	gatewayBody         string // posted to gatewayURL with {mac} and {ip} filled in
+	gatewayTimeout      time.Duration
+	gatewayClient       *http.Client
+	enableIPv6          bool
+	allowedSubnets      []*net.IPNet
+	wakeTargetOrder     []string
+	stopOnFirstSuccess  bool
+	parallelWakeSends   bool
+	reuseWakeConnections bool
 	port                int
+	sourcePort          int
 	timeout             time.Duration
 	retryAttempts       int
 	retryInterval       time.Duration
+	retryBackoff        string
+	retryMaxInterval    time.Duration
+	wakePollInterval    time.Duration
+	wakePollMaxInterval time.Duration // the poll interval doubles up to this while waiting; equal to wakePollInterval by default
+	wakeHealthyThreshold int          // consecutive healthy probes needed before a wake counts as done
+	autoWakeMode        string
+	autoWakeRetryAfter  time.Duration
+	maxClientWait       time.Duration // longest a blocking auto-wake holds one client; zero waits for the whole wake
+	coldResponseBody    string // replaces the auto-wake 503 bodies when set
+	coldResponseContentType string
+	wakeOnMethods       map[string]bool // methods that may wake the service or see the control page; nil allows all
+	nonWakeMethodStatus int             // sent to other methods while the service is down
+	fallbackProxy       *httputil.ReverseProxy // serves cold requests while waking; nil without fallbackURL
+	preWakeWebhookURL      string
+	preWakeWebhookTimeout  time.Duration
+	preWakeWebhookAttempts int
+	preWakeClient          *http.Client
+	progressSendPct     int
+	progressWaitPct     int
 	healthCheckInterval time.Duration
+	healthCheckJitter   time.Duration
+	jitterRand          *mathrand.Rand
+	healthFlapThreshold int
+	statusMaxStaleness  time.Duration // how far past healthCheckInterval status polls serve the cache while refreshing in the background
+	startupGracePeriod  time.Duration
+	startedAt           time.Time
+	warmHealthCacheOnStart bool
+	healthCheckMethod          string
+	healthCheckBody            string
+	healthCheckHeaders         map[string]string
+	forwardHealthAuthHeaders   []string // request headers copied onto request-triggered health probes; nil disables forwarding
+	healthCheckFollowRedirects bool
+	healthCheckMaxRedirects    int
+	healthCheckClientCert      *tls.Certificate
+	healthCheckGRPCService     string
+	readinessCheck             string
+	healthCheckUserAgent       string
+	healthCheckDisableKeepAlive bool
+	healthCheckProxyURL        *url.URL // proxy for HTTP health checks; nil connects directly
+	healthCheckExpectBody      string
+	healthCheckExpectBodyRegex *regexp.Regexp
+	healthCheckMaxLatency      time.Duration // slower probes count as unhealthy even with a 2xx; zero disables the check
+	maxHealthBodyBytes         int64
+	grpcTLSConfig              *tls.Config
+	packetRepeat        int
+	packetRepeatDelay   time.Duration
+	packetFormat        string // magic packet layout built by createMagicPacket
+	secureOnPassword    []byte // appended to the packet in packetFormat "secureon"
 	debug               bool
+	quietWhenHealthy    bool   // log only health transitions while healthy, even with debug
+	quietRequests       uint64 // requests passed through silently under quietWhenHealthy; guarded by activityMutex
+	dryRun              bool
+	maintenanceMode     bool
+	maintenanceMessage  string
+	maintenancePageTmpl *template.Template
 	enableControlPage   bool
 	controlPageTitle    string
 	serviceDescription  string
+	controlPageTitleTmpl   *texttemplate.Template // set when controlPageTitle uses template syntax
+	serviceDescriptionTmpl *texttemplate.Template // set when serviceDescription uses template syntax
+	controlPageTmpl     *template.Template
+	controlPageTemplatePath   string // reported by /_wol/config; controlPageTmpl holds the parsed template
+	controlPageTemplateInline bool
+	controlPageCustomCSS template.CSS
+	controlPageSecurityHeaders bool
+	controlPageCSP      string // Content-Security-Policy with "{nonce}" standing for the per-response nonce; empty sends none
+	controlPageLogoURL  string
+	language            string
+	statusPollIntervalMs int
+	statusPollMaxIntervalMs int
+	statusPollBackoff    float64
 	
 	// Auto-redirect configuration
 	autoRedirect            bool
 	redirectDelay           time.Duration
+	autoRedirectStablePolls int // consecutive healthy status polls the page waits for before auto-redirecting
+	redirectTarget          string // replaces the original URL as the redirect destination when set
+	redirectStatusCode      int    // 302 or 303 continue as a GET; 307 and 308 repeat the POST
+	bypassSessionDuration   time.Duration // how long a "Go to Service" cookie skips the control page; zero disables it
+	bypassSessionKey        []byte        // signs bypass session cookies; generated at startup, guarded by bypassMutex
 	skipControlPageWhenHealthy bool
+	contentNegotiation  bool // answer non-browser clients with JSON instead of the control page
 	
 	// Dashboard configuration
 	showPowerOffButton  bool
 	confirmPowerOff     bool
+	powerOffConfirmMessage string
+	powerOffRequireTyping  bool
 	hideRedirectButton  bool
+	showTargetDetails   bool // show macAddress and ipAddress on the control page to allowedControlIPs clients
+	enableCSRFProtection bool
+	allowedControlIPs   []*net.IPNet
+	readOnlyControlIPs  []*net.IPNet // clients that may watch the control page but not wake or power off
+	controlRoleHeader   string
+	readOnlyRoles       []string
+	trustForwardedFor   bool
+	adminToken          string
 	
 	// Power-off configuration
+	powerOffMethod      string
 	powerOffCommand     string
+	sshHost             string
+	sshCommand          string
+	sshRunner           commandRunner
+	powerOffDrainPeriod time.Duration
+	powerOffTimeout     time.Duration // bounds the SSH session and the wait for the service to go down
+	shutdownCheck       string // http(s) or tcp:// URL confirming the service is down after power-off; empty inverts the health check
+	draining            bool // new requests are refused while a power-off drains; guarded by wakeMutex
+	
+	// Idle shutdown configuration
+	idleShutdownTimeout time.Duration
+	lastActivity        time.Time
+	activityMutex       sync.RWMutex
+	
+	// Keep-alive configuration
+	keepAliveInterval    time.Duration
+	keepAliveURL         string        // requested instead of the health check when set
+	keepAliveIdleTimeout time.Duration // keep-alives stop once the service has seen no traffic for this long
+	
+	// Schedule configuration
+	schedule            []scheduleWindow
+	scheduleLocation    *time.Location
+	scheduleAwake       bool
+	scheduleMutex       sync.Mutex
 	
+	// MQTT state publishing
+	publisher           statePublisher
+	mqttTopic           string
+	events              chan []byte
+	
+	// Audit records of wakes and power-offs; written to stdout without auditLogPath
+	auditLogPath        string
+	auditMutex          sync.Mutex
+	
+	// Tracing
+	enableTracing       bool
+	spanExporter        spanExporter
+	
+	// Circuit breaker suspending wakes after repeated failures
+	circuitBreakerThreshold int
+	circuitBreakerWindow    time.Duration
+	circuitBreakerCooldown  time.Duration
+	breakerState            string
+	breakerFailures         int
+	breakerFirstFailure     time.Time
+	breakerOpenedAt         time.Time
+	breakerMutex            sync.Mutex
+	
+	// operationSlots is a semaphore bounding the background wake and power-off sequences in flight
+	operationSlots      chan struct{}
+	operationSlot       *operationSlot // slot held by the running sequence; released early by forceResetOperation; guarded by wakeMutex
+	
+	ctx                 context.Context // cancelled when Traefik tears down the middleware
+	cancel              context.CancelFunc
+	now                 func() time.Time
+	sleep               func(ctx context.Context, d time.Duration) bool
+	sendPacket          func(packet []byte, targetAddr string) error
+	sendFrame           func(ifaceName string, frame []byte) error // sends a raw Ethernet frame for wakeTransport "ethernet"; set by SetFrameSender, guarded by sendFrameMutex
+	sendFrameMutex      sync.RWMutex
+	interfaces          interfaceProvider // lists network interfaces and their addresses for broadcast discovery
+	lookupIP            func(host string) (*net.IPAddr, error) // resolves wake target hostnames
+	resolveCache        map[string]resolvedHost // by hostname; guarded by resolveMutex
+	resolveMutex        sync.Mutex
+	ethernetFallback    sync.Once
+	wakeConns           map[string]*net.UDPConn // per-target connections kept for a wake under reuseWakeConnections; guarded by wakeConnsMutex
+	wakeConnsMutex      sync.Mutex
+	wakeHooks           WakeHooks // set by SetWakeHooks; guarded by wakeHooksMutex
+	wakeHooksMutex      sync.RWMutex
+	httpClient          *http.Client
 	healthCache         *healthStatus
 	healthMutex         sync.RWMutex
+	dialFailure         string // classifyDialError of the most recent probe; guarded by dialFailureMutex
+	dialFailureMutex    sync.Mutex
+	healthLatency       time.Duration // duration of the most recent HTTP or gRPC probe; guarded by healthLatencyMutex
+	healthLatencyMutex  sync.Mutex
+	healthFlight        *healthCall // probe in flight, shared by concurrent callers; guarded by healthFlightMutex
+	healthFlightMutex   sync.Mutex
+	healthRefreshing    bool // a background refresh started by a status poll is running; guarded by healthFlightMutex
+	autoWakeFlight      *autoWakeCall // blocking auto-wake in flight; guarded by autoWakeFlightMutex
+	autoWakeFlightMutex sync.Mutex
 	wakeCache           *wakeStatus
 	wakeMutex           sync.RWMutex
+	wakeChanged         chan struct{} // closed and replaced on every wakeCache update
+	operationCancel     context.CancelCauseFunc // aborts the running wake or power-off; guarded by wakeMutex
+	operationGen        uint64                  // identifies the current operation; guarded by wakeMutex
 	bypassCache         *bypassStatus
 	bypassMutex         sync.RWMutex
+	stats               availabilityStats
+	statsMutex          sync.Mutex
 }
 
 // New creates a new WOL plugin.
 func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
-	if config.HealthCheck == "" {
-		return nil, fmt.Errorf("healthCheck URL is required")
+	return newWithClock(ctx, next, config, name, time.Now)
+}
+
+// newWithClock creates a plugin that reads time from now, so startup and activity timestamps share the injected clock
+func newWithClock(ctx context.Context, next http.Handler, config *Config, name string, now func() time.Time) (http.Handler, error) {
+	// Collect every invalid field rather than stopping at the first, so a configuration can be fixed in one pass
+	var problems []error
+	invalid := func(err error) {
+		problems = append(problems, err)
+	}
+
+	// A single healthCheck is treated as the first entry of healthChecks. Without one, healthCheckPath is
+	// resolved against serviceURL, or against the service's ipAddress over plain HTTP.
+	var healthChecks []string
+	if config.HealthCheck != "" {
+		healthChecks = append(healthChecks, config.HealthCheck)
+	} else if config.HealthCheckPath != "" {
+		if healthCheck, err := resolveHealthCheckPath(config.HealthCheckPath, config.ServiceURL, config.IPAddress); err != nil {
+			invalid(err)
+		} else {
+			healthChecks = append(healthChecks, healthCheck)
+		}
+	}
+	healthChecks = append(healthChecks, config.HealthChecks...)
+
+	healthCheckType := strings.ToLower(strings.TrimSpace(config.HealthCheckType))
+	switch healthCheckType {
+	case "":
+		healthCheckType = healthCheckTypeHTTP
+	case healthCheckTypeHTTP, healthCheckTypeGRPC:
+	case healthCheckTypeARP:
+		// The ARP table is read from /proc, which only exists on Linux
+		if runtime.GOOS != "linux" {
+			invalid(fmt.Errorf("healthCheckType %q is only supported on Linux", healthCheckTypeARP))
+		}
+	default:
+		invalid(fmt.Errorf("invalid healthCheckType %q: must be %q, %q or %q", config.HealthCheckType, healthCheckTypeHTTP, healthCheckTypeGRPC, healthCheckTypeARP))
+	}
+
+	if len(healthChecks) == 0 && healthCheckType == healthCheckTypeHTTP {
+		invalid(fmt.Errorf("healthCheck URL is required"))
+	}
+	if len(healthChecks) == 0 && healthCheckType == healthCheckTypeGRPC {
+		invalid(fmt.Errorf("healthCheck target is required for healthCheckType %q", healthCheckTypeGRPC))
 	}
 	if config.MacAddress == "" {
-		return nil, fmt.Errorf("macAddress is required")
+		invalid(fmt.Errorf("macAddress is required"))
+	}
+	for _, healthCheck := range healthChecks {
+		if healthCheckType == healthCheckTypeGRPC {
+			if _, _, err := parseGRPCHealthTarget(healthCheck); err != nil {
+				invalid(err)
+			}
+			continue
+		}
+		if err := validateHealthCheckURL(healthCheck); err != nil {
+			invalid(err)
+		}
+	}
+	if config.ReadinessCheck != "" {
+		if err := validateCheckURL("readinessCheck", config.ReadinessCheck); err != nil {
+			invalid(err)
+		}
+	}
+	var fallbackURL *url.URL
+	if config.FallbackURL != "" {
+		if err := validateCheckURL("fallbackURL", config.FallbackURL); err != nil {
+			invalid(err)
+		} else {
+			fallbackURL, _ = url.Parse(config.FallbackURL)
+		}
+	}
+	if config.PreWakeWebhookURL != "" {
+		if err := validateCheckURL("preWakeWebhookURL", config.PreWakeWebhookURL); err != nil {
+			invalid(err)
+		}
+	}
+
+	healthCheckUserAgent := config.HealthCheckUserAgent
+	if healthCheckUserAgent == "" {
+		healthCheckUserAgent = defaultUserAgent
+	}
+
+	healthCheckMode := strings.ToLower(strings.TrimSpace(config.HealthCheckMode))
+	if healthCheckMode == "" {
+		healthCheckMode = healthCheckModeAll
+	}
+	if healthCheckMode != healthCheckModeAll && healthCheckMode != healthCheckModeAny {
+		invalid(fmt.Errorf("invalid healthCheckMode %q: must be %q or %q", config.HealthCheckMode, healthCheckModeAll, healthCheckModeAny))
 	}
 
 	// Parse basic configuration
 	port, err := strconv.Atoi(config.Port)
 	if err != nil {
-		return nil, fmt.Errorf("invalid port: %v", err)
+		invalid(fmt.Errorf("invalid port: %v", err))
+	}
+
+	// Source port 0 (or unset) lets the OS pick an ephemeral port
+	sourcePort := 0
+	if config.SourcePort != "" {
+		sourcePort, err = strconv.Atoi(config.SourcePort)
+		if err != nil {
+			invalid(fmt.Errorf("invalid sourcePort: %v", err))
+		} else if sourcePort < 0 || sourcePort > 65535 {
+			invalid(fmt.Errorf("sourcePort must be between 0 and 65535"))
+		}
+	}
+
+	allowedSubnets, err := parseAllowedSubnets(config.AllowedSubnets)
+	if err != nil {
+		invalid(err)
+	}
+
+	broadcastAddresses, err := parseBroadcastAddresses(config.BroadcastAddress, config.BroadcastAddresses)
+	if err != nil {
+		invalid(err)
+	}
+
+	// A hostname ipAddress is looked up on every send unless its address may be reused
+	var wakeTargetResolveTTL time.Duration
+	if config.WakeTargetResolveTTL != "" {
+		wakeTargetResolveTTL, err = parseDurationField("wakeTargetResolveTTL", config.WakeTargetResolveTTL)
+		if err != nil {
+			invalid(err)
+		} else if wakeTargetResolveTTL < 0 {
+			invalid(fmt.Errorf("wakeTargetResolveTTL must not be negative"))
+		}
+	}
+
+	wakeTargetOrder, err := parseWakeTargetOrder(config.WakeTargetOrder, config.IPAddress)
+	if err != nil {
+		invalid(err)
+	}
+	// Concurrent sends have no order in which to stop
+	if config.ParallelWakeSends && config.StopOnFirstSuccess {
+		invalid(fmt.Errorf("parallelWakeSends cannot be combined with stopOnFirstSuccess"))
+	}
+	// Only one socket can be bound to sourcePort, so concurrent sends must share the one reuseWakeConnections keeps
+	if config.ParallelWakeSends && sourcePort > 0 && !config.ReuseWakeConnections {
+		invalid(fmt.Errorf("parallelWakeSends with sourcePort requires reuseWakeConnections"))
+	}
+
+	wakeTransport := strings.ToLower(strings.TrimSpace(config.WakeTransport))
+	switch wakeTransport {
+	case "":
+		wakeTransport = wakeTransportUDP
+	case wakeTransportUDP:
+	case wakeTransportEthernet:
+		// Raw frames go out of one specific interface
+		if config.NetworkInterface == "" {
+			invalid(fmt.Errorf("networkInterface is required when wakeTransport is %q", wakeTransportEthernet))
+		}
+	default:
+		invalid(fmt.Errorf("invalid wakeTransport %q: must be %q or %q", config.WakeTransport, wakeTransportUDP, wakeTransportEthernet))
+	}
+
+	wakeMethod := strings.ToLower(strings.TrimSpace(config.WakeMethod))
+	switch wakeMethod {
+	case "":
+		wakeMethod = wakeMethodLocal
+	case wakeMethodLocal:
+	case wakeMethodGateway, wakeMethodBoth:
+		if config.GatewayURL == "" {
+			invalid(fmt.Errorf("gatewayURL is required when wakeMethod is %q", wakeMethod))
+		}
+	default:
+		invalid(fmt.Errorf("invalid wakeMethod %q: must be %q, %q or %q", config.WakeMethod, wakeMethodLocal, wakeMethodGateway, wakeMethodBoth))
+	}
+	if config.GatewayURL != "" {
+		if err := validateCheckURL("gatewayURL", config.GatewayURL); err != nil {
+			invalid(err)
+		} else if wakeMethod == wakeMethodLocal {
+			invalid(fmt.Errorf("gatewayURL requires wakeMethod %q or %q", wakeMethodGateway, wakeMethodBoth))
+		}
+	}
+	gatewayBody := config.GatewayBody
+	if gatewayBody == "" {
+		gatewayBody = defaultGatewayBody
+	}
+	gatewayTimeout := defaultGatewayTimeout
+	if config.GatewayTimeout != "" {
+		gatewayTimeout, err = parseDurationField("gatewayTimeout", config.GatewayTimeout)
+		if err != nil {
+			invalid(err)
+		} else if gatewayTimeout <= 0 {
+			invalid(fmt.Errorf("gatewayTimeout must be positive"))
+		}
+	}
+
+	allowedControlIPs, err := parseIPAllowlist("allowedControlIPs", config.AllowedControlIPs)
+	if err != nil {
+		invalid(err)
+	}
+	readOnlyControlIPs, err := parseIPAllowlist("readOnlyControlIPs", config.ReadOnlyControlIPs)
+	if err != nil {
+		invalid(err)
+	}
+	// Without an allowlist every visitor would see which machine the page wakes
+	if config.ShowTargetDetails && len(config.AllowedControlIPs) == 0 {
+		invalid(fmt.Errorf("showTargetDetails requires allowedControlIPs"))
+	}
+	if config.ControlRoleHeader == "" && len(config.ReadOnlyRoles) > 0 {
+		invalid(fmt.Errorf("readOnlyRoles requires controlRoleHeader"))
+	} else if config.ControlRoleHeader != "" && len(config.ReadOnlyRoles) == 0 {
+		invalid(fmt.Errorf("controlRoleHeader requires readOnlyRoles"))
 	}
 
-	timeout, err := strconv.Atoi(config.Timeout)
+	timeout, err := parseDurationField("timeout", config.Timeout)
 	if err != nil {
-		return nil, fmt.Errorf("invalid timeout: %v", err)
+		invalid(err)
 	}
 
 	retryAttempts, err := strconv.Atoi(config.RetryAttempts)
 	if err != nil {
-		return nil, fmt.Errorf("invalid retryAttempts: %v", err)
+		invalid(fmt.Errorf("invalid retryAttempts: %v", err))
 	}
 
-	retryInterval, err := strconv.Atoi(config.RetryInterval)
+	retryInterval, err := parseDurationField("retryInterval", config.RetryInterval)
 	if err != nil {
-		return nil, fmt.Errorf("invalid retryInterval: %v", err)
+		invalid(err)
+	}
+
+	retryBackoff := strings.ToLower(strings.TrimSpace(config.RetryBackoff))
+	switch retryBackoff {
+	case "":
+		retryBackoff = retryBackoffFixed
+	case retryBackoffFixed, retryBackoffLinear, retryBackoffExponential:
+	default:
+		invalid(fmt.Errorf("invalid retryBackoff %q: must be fixed, linear or exponential", config.RetryBackoff))
+	}
+
+	// Unset means no cap on the backoff interval
+	var retryMaxInterval time.Duration
+	if config.RetryMaxInterval != "" {
+		retryMaxInterval, err = parseDurationField("retryMaxInterval", config.RetryMaxInterval)
+		if err != nil {
+			invalid(err)
+		}
+	}
+
+	wakePollInterval := defaultWakePollInterval
+	if config.WakePollInterval != "" {
+		wakePollInterval, err = parseDurationField("wakePollInterval", config.WakePollInterval)
+		if err != nil {
+			invalid(err)
+		} else if wakePollInterval <= 0 {
+			invalid(fmt.Errorf("wakePollInterval must be positive"))
+		}
+	}
+	// Unset keeps polling at wakePollInterval
+	wakePollMaxInterval := wakePollInterval
+	if config.WakePollMaxInterval != "" {
+		wakePollMaxInterval, err = parseDurationField("wakePollMaxInterval", config.WakePollMaxInterval)
+		if err != nil {
+			invalid(err)
+		} else if wakePollMaxInterval < wakePollInterval {
+			invalid(fmt.Errorf("wakePollMaxInterval must not be less than wakePollInterval"))
+		}
+	}
+
+	wakeHealthyThreshold := 1
+	if config.WakeHealthyThreshold != "" {
+		wakeHealthyThreshold, err = strconv.Atoi(config.WakeHealthyThreshold)
+		if err != nil {
+			invalid(fmt.Errorf("invalid wakeHealthyThreshold: %v", err))
+		} else if wakeHealthyThreshold < 1 {
+			invalid(fmt.Errorf("wakeHealthyThreshold must be positive"))
+		}
+	}
+
+	autoWakeMode := strings.ToLower(strings.TrimSpace(config.AutoWakeMode))
+	switch autoWakeMode {
+	case "":
+		autoWakeMode = autoWakeModeBlocking
+	case autoWakeModeBlocking, autoWakeModeAsync:
+	default:
+		invalid(fmt.Errorf("invalid autoWakeMode %q: must be %q or %q", config.AutoWakeMode, autoWakeModeBlocking, autoWakeModeAsync))
+	}
+
+	autoWakeRetryAfter := defaultAutoWakeRetryAfter
+	if config.AutoWakeRetryAfter != "" {
+		autoWakeRetryAfter, err = parseDurationField("autoWakeRetryAfter", config.AutoWakeRetryAfter)
+		if err != nil {
+			invalid(err)
+		} else if autoWakeRetryAfter <= 0 {
+			invalid(fmt.Errorf("autoWakeRetryAfter must be positive"))
+		}
+	}
+	var maxClientWait time.Duration
+	if config.MaxClientWait != "" {
+		maxClientWait, err = parseDurationField("maxClientWait", config.MaxClientWait)
+		if err != nil {
+			invalid(err)
+		} else if maxClientWait < 0 {
+			invalid(fmt.Errorf("maxClientWait must not be negative"))
+		}
+	}
+
+	coldResponseContentType := config.ColdResponseContentType
+	if config.ColdResponseBody == "" && coldResponseContentType != "" {
+		invalid(fmt.Errorf("coldResponseContentType requires coldResponseBody"))
+	} else if coldResponseContentType == "" {
+		coldResponseContentType = "text/html; charset=utf-8"
+	}
+
+	var wakeOnMethods map[string]bool
+	for _, method := range config.WakeOnMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" || strings.IndexFunc(method, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+			invalid(fmt.Errorf("invalid wakeOnMethods entry %q", method))
+			continue
+		}
+		if wakeOnMethods == nil {
+			wakeOnMethods = make(map[string]bool)
+		}
+		wakeOnMethods[method] = true
+	}
+	nonWakeMethodStatus := http.StatusServiceUnavailable
+	if config.NonWakeMethodStatus != "" {
+		nonWakeMethodStatus, err = strconv.Atoi(config.NonWakeMethodStatus)
+		if err != nil {
+			invalid(fmt.Errorf("invalid nonWakeMethodStatus: %v", err))
+		} else if nonWakeMethodStatus < 400 || nonWakeMethodStatus > 599 {
+			invalid(fmt.Errorf("nonWakeMethodStatus must be a 4xx or 5xx status code"))
+		}
+	}
+
+	preWakeWebhookTimeout := defaultPreWakeWebhookTimeout
+	if config.PreWakeWebhookTimeout != "" {
+		preWakeWebhookTimeout, err = parseDurationField("preWakeWebhookTimeout", config.PreWakeWebhookTimeout)
+		if err != nil {
+			invalid(err)
+		} else if preWakeWebhookTimeout <= 0 {
+			invalid(fmt.Errorf("preWakeWebhookTimeout must be positive"))
+		}
 	}
 
-	healthCheckInterval, err := strconv.Atoi(config.HealthCheckInterval)
+	preWakeWebhookAttempts := defaultPreWakeWebhookAttempts
+	if config.PreWakeWebhookAttempts != "" {
+		preWakeWebhookAttempts, err = strconv.Atoi(config.PreWakeWebhookAttempts)
+		if err != nil {
+			invalid(fmt.Errorf("invalid preWakeWebhookAttempts: %v", err))
+		} else if preWakeWebhookAttempts <= 0 {
+			invalid(fmt.Errorf("preWakeWebhookAttempts must be positive"))
+		}
+	}
+
+	progressSendPct, err := parseProgressPct("progressSendPct", config.ProgressSendPct, defaultProgressSendPct)
 	if err != nil {
-		return nil, fmt.Errorf("invalid healthCheckInterval: %v", err)
+		invalid(err)
+	}
+	progressWaitPct, err := parseProgressPct("progressWaitPct", config.ProgressWaitPct, defaultProgressWaitPct)
+	if err != nil {
+		invalid(err)
+	} else if progressSendPct > progressWaitPct {
+		invalid(fmt.Errorf("progressSendPct must not exceed progressWaitPct"))
 	}
 
-	// Parse auto-redirect configuration
-	redirectDelay, err := strconv.Atoi(config.RedirectDelay)
+	healthCheckInterval, err := parseDurationField("healthCheckInterval", config.HealthCheckInterval)
 	if err != nil {
-		return nil, fmt.Errorf("invalid redirectDelay: %v", err)
+		invalid(err)
 	}
 
-	// Validate power-off configuration if enabled
-	if config.ShowPowerOffButton && config.PowerOffCommand == "" {
-		return nil, fmt.Errorf("powerOffCommand is required when showPowerOffButton is enabled")
+	healthCheckJitter, err := parseHealthCheckJitter(config.HealthCheckJitter, healthCheckInterval)
+	if err != nil {
+		invalid(err)
 	}
 
-	// Set default values for control page settings
-	controlPageTitle := config.ControlPageTitle
-	if controlPageTitle == "" {
-		controlPageTitle = "Service Control"
+	healthFlapThreshold := 1
+	if config.HealthFlapThreshold != "" {
+		healthFlapThreshold, err = strconv.Atoi(config.HealthFlapThreshold)
+		if err != nil {
+			invalid(fmt.Errorf("invalid healthFlapThreshold: %v", err))
+		} else if healthFlapThreshold < 1 {
+			invalid(fmt.Errorf("healthFlapThreshold must be positive"))
+		}
 	}
-	serviceDescription := config.ServiceDescription
-	if serviceDescription == "" {
-		serviceDescription = "Service"
+
+	var forwardHealthAuthHeaders []string
+	if config.ForwardHealthAuthFromRequest {
+		forwardHealthAuthHeaders, err = parseForwardHealthAuthHeaders(config.ForwardHealthAuthHeaders)
+		if err != nil {
+			invalid(err)
+		}
+	} else if len(config.ForwardHealthAuthHeaders) > 0 {
+		invalid(fmt.Errorf("forwardHealthAuthHeaders requires forwardHealthAuthFromRequest"))
 	}
 
-	return &WOLPlugin{
-		next:                next,
-		name:                name,
-		healthCheck:         config.HealthCheck,
-		macAddress:          config.MacAddress,
-		ipAddress:           config.IPAddress,
-		broadcastAddress:    config.BroadcastAddress,
-		networkInterface:    config.NetworkInterface,
-		port:                port,
-		timeout:             time.Duration(timeout) * time.Second,
-		retryAttempts:       retryAttempts,
-		retryInterval:       time.Duration(retryInterval) * time.Second,
-		healthCheckInterval: time.Duration(healthCheckInterval) * time.Second,
-		debug:               config.Debug,
-		enableControlPage:   config.EnableControlPage,
-		controlPageTitle:    controlPageTitle,
-		serviceDescription:  serviceDescription,
-		
-		// Auto-redirect configuration
-		autoRedirect:            config.AutoRedirect,
-		redirectDelay:           time.Duration(redirectDelay) * time.Second,
-		skipControlPageWhenHealthy: config.SkipControlPageWhenHealthy,
-		
-		// Dashboard configuration
-		showPowerOffButton:  config.ShowPowerOffButton,
-		confirmPowerOff:     config.ConfirmPowerOff,
-		hideRedirectButton:  config.HideRedirectButton,
-		
-		// Power-off configuration
-		powerOffCommand:     config.PowerOffCommand,
-		
-		healthCache:         &healthStatus{},
-		healthMutex:         sync.RWMutex{},
-		wakeCache:           &wakeStatus{},
-		wakeMutex:           sync.RWMutex{},
-		bypassCache:         &bypassStatus{},
-		bypassMutex:         sync.RWMutex{},
-	}, nil
-}
+	var statusMaxStaleness time.Duration
+	if config.StatusMaxStaleness != "" {
+		statusMaxStaleness, err = parseDurationField("statusMaxStaleness", config.StatusMaxStaleness)
+		if err != nil {
+			invalid(err)
+		} else if statusMaxStaleness < 0 {
+			invalid(fmt.Errorf("statusMaxStaleness must not be negative"))
+		}
+	}
 
-// controlPageTemplate contains the embedded HTML template for the control page
-const controlPageTemplate = `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>{{.Title}}</title>
-    <style>
-        * {
-            margin: 0;
-            padding: 0;
-            box-sizing: border-box;
-        }
-        
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', system-ui, sans-serif;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            min-height: 100vh;
-            display: flex;
-            align-items: center;
-            justify-content: center;
-            padding: 20px;
-        }
-        
-        .container {
-            background: white;
-            border-radius: 20px;
-            box-shadow: 0 20px 60px rgba(0,0,0,0.1);
-            padding: 40px;
-            max-width: 500px;
-            width: 100%;
-            text-align: center;
-        }
-        
-        .service-icon {
-            width: 80px;
-            height: 80px;
-            background: #f0f0f0;
-            border-radius: 50%;
-            margin: 0 auto 20px;
-            display: flex;
-            align-items: center;
-            justify-content: center;
-            font-size: 32px;
-        }
-        
-        .status-indicator {
-            width: 20px;
-            height: 20px;
-            border-radius: 50%;
-            position: absolute;
-            top: 5px;
-            right: 5px;
-            border: 3px solid white;
-        }
-        
-        .status-down { background: #ff4757; }
-        .status-waking { background: #ffa502; animation: pulse 2s infinite; }
-        .status-up { background: #2ed573; }
-        
-        @keyframes pulse {
-            0%, 100% { opacity: 1; }
-            50% { opacity: 0.5; }
-        }
-        
-        h1 {
-            color: #2c3e50;
-            margin-bottom: 10px;
-            font-size: 28px;
-            font-weight: 700;
-        }
-        
-        .service-name {
-            color: #7f8c8d;
+	var startupGracePeriod time.Duration
+	if config.StartupGracePeriod != "" {
+		startupGracePeriod, err = parseDurationField("startupGracePeriod", config.StartupGracePeriod)
+		if err != nil {
+			invalid(err)
+		} else if startupGracePeriod < 0 {
+			invalid(fmt.Errorf("startupGracePeriod must not be negative"))
+		}
+	}
+
+	healthCheckMethod, err := parseHealthCheckMethod(config.HealthCheckMethod, config.HealthCheckBody)
+	if err != nil {
+		invalid(err)
+	}
+
+	var healthCheckExpectBodyRegex *regexp.Regexp
+	if config.HealthCheckExpectBodyRegex != "" {
+		healthCheckExpectBodyRegex, err = regexp.Compile(config.HealthCheckExpectBodyRegex)
+		if err != nil {
+			invalid(fmt.Errorf("invalid healthCheckExpectBodyRegex: %v", err))
+		}
+	}
+
+	var healthCheckMaxLatency time.Duration
+	if config.HealthCheckMaxLatency != "" {
+		healthCheckMaxLatency, err = parseDurationField("healthCheckMaxLatency", config.HealthCheckMaxLatency)
+		if err != nil {
+			invalid(err)
+		} else if healthCheckMaxLatency <= 0 {
+			invalid(fmt.Errorf("healthCheckMaxLatency must be positive"))
+		} else if healthCheckType == healthCheckTypeARP {
+			invalid(fmt.Errorf("healthCheckMaxLatency cannot be combined with healthCheckType %q", healthCheckTypeARP))
+		}
+	}
+
+	maxHealthBodyBytes := int64(defaultMaxHealthBodyBytes)
+	if config.MaxHealthBodyBytes != "" {
+		maxHealthBodyBytes, err = strconv.ParseInt(config.MaxHealthBodyBytes, 10, 64)
+		if err != nil {
+			invalid(fmt.Errorf("invalid maxHealthBodyBytes: %v", err))
+		} else if maxHealthBodyBytes <= 0 {
+			invalid(fmt.Errorf("maxHealthBodyBytes must be positive"))
+		}
+	}
+
+	// Unset keeps Go's default limit of 10 redirects
+	healthCheckMaxRedirects := 0
+	if config.HealthCheckMaxRedirects != "" {
+		healthCheckMaxRedirects, err = strconv.Atoi(config.HealthCheckMaxRedirects)
+		if err != nil {
+			invalid(fmt.Errorf("invalid healthCheckMaxRedirects: %v", err))
+		} else if healthCheckMaxRedirects < 1 {
+			invalid(fmt.Errorf("healthCheckMaxRedirects must be at least 1"))
+		}
+	}
+
+	healthCheckClientCert, err := loadClientCertificate(config.HealthCheckClientCert, config.HealthCheckClientKey)
+	if err != nil {
+		invalid(err)
+	}
+
+	// Health checks connect directly unless a proxy is configured; the environment is deliberately ignored
+	var healthCheckProxyURL *url.URL
+	if config.HealthCheckProxyURL != "" {
+		healthCheckProxyURL, err = parseProxyURL(config.HealthCheckProxyURL)
+		if err != nil {
+			invalid(fmt.Errorf("invalid healthCheckProxyUrl: %v", err))
+		}
+	}
+
+	// Parse magic packet repetition, defaulting to a single send per address
+	packetRepeat := 1
+	if config.PacketRepeat != "" {
+		packetRepeat, err = strconv.Atoi(config.PacketRepeat)
+		if err != nil {
+			invalid(fmt.Errorf("invalid packetRepeat: %v", err))
+		} else if packetRepeat < 1 {
+			invalid(fmt.Errorf("packetRepeat must be at least 1"))
+		}
+	}
+
+	var packetRepeatDelay time.Duration
+	if config.PacketRepeatDelay != "" {
+		packetRepeatDelay, err = parseDurationField("packetRepeatDelay", config.PacketRepeatDelay)
+		if err != nil {
+			invalid(err)
+		} else if packetRepeatDelay < 0 {
+			invalid(fmt.Errorf("packetRepeatDelay must not be negative"))
+		}
+	}
+
+	packetFormat := strings.ToLower(strings.TrimSpace(config.PacketFormat))
+	var secureOnPassword []byte
+	switch packetFormat {
+	case "":
+		packetFormat = packetFormatStandard
+	case packetFormatStandard:
+	case packetFormatSecureOn:
+		if config.SecureOnPassword == "" {
+			invalid(fmt.Errorf("secureOnPassword is required when packetFormat is %q", packetFormatSecureOn))
+		}
+	default:
+		invalid(fmt.Errorf("invalid packetFormat %q: must be %q or %q", config.PacketFormat, packetFormatStandard, packetFormatSecureOn))
+	}
+	if config.SecureOnPassword != "" {
+		if packetFormat != packetFormatSecureOn {
+			invalid(fmt.Errorf("secureOnPassword requires packetFormat %q", packetFormatSecureOn))
+		} else if secureOnPassword, err = parseSecureOnPassword(config.SecureOnPassword); err != nil {
+			invalid(fmt.Errorf("invalid secureOnPassword: %v", err))
+		}
+	}
+
+	// Parse auto-redirect configuration
+	redirectDelay, err := parseDurationField("redirectDelay", config.RedirectDelay)
+	if err != nil {
+		invalid(err)
+	}
+	// A single healthy probe is enough unless the service is known to flap while starting
+	autoRedirectStablePolls := 1
+	if config.AutoRedirectRequireStableHealthy != "" {
+		autoRedirectStablePolls, err = strconv.Atoi(config.AutoRedirectRequireStableHealthy)
+		if err != nil {
+			invalid(fmt.Errorf("invalid autoRedirectRequireStableHealthy: %v", err))
+		} else if autoRedirectStablePolls <= 0 {
+			invalid(fmt.Errorf("autoRedirectRequireStableHealthy must be positive"))
+		}
+	}
+	if config.RedirectTarget != "" {
+		if strings.HasPrefix(config.RedirectTarget, "/") {
+			if safeRedirectPath(config.RedirectTarget) != config.RedirectTarget {
+				invalid(fmt.Errorf("invalid redirectTarget %q: must be a local path outside /_wol/ or an absolute URL", config.RedirectTarget))
+			}
+		} else if err := validateCheckURL("redirectTarget", config.RedirectTarget); err != nil {
+			invalid(err)
+		}
+	}
+	redirectStatusCode := http.StatusFound
+	if config.RedirectStatusCode != "" {
+		redirectStatusCode, err = strconv.Atoi(config.RedirectStatusCode)
+		if err != nil {
+			invalid(fmt.Errorf("invalid redirectStatusCode: %v", err))
+		} else if redirectStatusCode != http.StatusFound && redirectStatusCode != http.StatusSeeOther &&
+			redirectStatusCode != http.StatusTemporaryRedirect && redirectStatusCode != http.StatusPermanentRedirect {
+			invalid(fmt.Errorf("redirectStatusCode must be 302, 303, 307 or 308"))
+		}
+	}
+	var bypassSessionDuration time.Duration
+	var bypassSessionKey []byte
+	if config.BypassSessionDuration != "" {
+		bypassSessionDuration, err = parseDurationField("bypassSessionDuration", config.BypassSessionDuration)
+		if err != nil {
+			invalid(err)
+		} else if bypassSessionDuration <= 0 {
+			invalid(fmt.Errorf("bypassSessionDuration must be positive"))
+		} else {
+			bypassSessionKey = make([]byte, bypassSessionKeyBytes)
+			if _, err := rand.Read(bypassSessionKey); err != nil {
+				invalid(fmt.Errorf("failed to generate bypass session key: %v", err))
+			}
+		}
+	}
+
+	statusPollIntervalMs := defaultStatusPollIntervalMs
+	if config.StatusPollIntervalMs != "" {
+		statusPollIntervalMs, err = strconv.Atoi(config.StatusPollIntervalMs)
+		if err != nil {
+			invalid(fmt.Errorf("invalid statusPollIntervalMs: %v", err))
+		} else if statusPollIntervalMs < minStatusPollIntervalMs || statusPollIntervalMs > maxStatusPollIntervalMs {
+			invalid(fmt.Errorf("statusPollIntervalMs must be between %d and %d", minStatusPollIntervalMs, maxStatusPollIntervalMs))
+		}
+	}
+
+	// Without a multiplier the page keeps polling at statusPollIntervalMs
+	statusPollBackoff := 1.0
+	if config.StatusPollBackoff != "" {
+		statusPollBackoff, err = strconv.ParseFloat(config.StatusPollBackoff, 64)
+		if err != nil {
+			invalid(fmt.Errorf("invalid statusPollBackoff: %v", err))
+		} else if statusPollBackoff < 1 || statusPollBackoff > maxStatusPollBackoff {
+			invalid(fmt.Errorf("statusPollBackoff must be between 1 and %v", maxStatusPollBackoff))
+		}
+	}
+
+	statusPollMaxIntervalMs := defaultStatusPollMaxIntervalMs
+	if config.StatusPollMaxIntervalMs != "" {
+		statusPollMaxIntervalMs, err = strconv.Atoi(config.StatusPollMaxIntervalMs)
+		if err != nil {
+			invalid(fmt.Errorf("invalid statusPollMaxIntervalMs: %v", err))
+		} else if statusPollMaxIntervalMs < statusPollIntervalMs || statusPollMaxIntervalMs > maxStatusPollIntervalMs {
+			invalid(fmt.Errorf("statusPollMaxIntervalMs must be between statusPollIntervalMs (%d) and %d", statusPollIntervalMs, maxStatusPollIntervalMs))
+		}
+	}
+	if statusPollMaxIntervalMs < statusPollIntervalMs {
+		statusPollMaxIntervalMs = statusPollIntervalMs
+	}
+
+	// Unset or zero powers off without draining
+	var powerOffDrainPeriod time.Duration
+	if config.PowerOffDrainPeriod != "" {
+		powerOffDrainPeriod, err = parseDurationField("powerOffDrainPeriod", config.PowerOffDrainPeriod)
+		if err != nil {
+			invalid(err)
+		} else if powerOffDrainPeriod < 0 {
+			invalid(fmt.Errorf("powerOffDrainPeriod must not be negative"))
+		}
+	}
+
+	// The power-off gets as long as a wake unless configured separately
+	powerOffTimeout := timeout
+	if config.PowerOffTimeout != "" {
+		powerOffTimeout, err = parseDurationField("powerOffTimeout", config.PowerOffTimeout)
+		if err != nil {
+			invalid(err)
+		} else if powerOffTimeout <= 0 {
+			invalid(fmt.Errorf("powerOffTimeout must be positive"))
+		}
+	}
+
+	if config.ShutdownCheck != "" {
+		if err := validateShutdownCheck(config.ShutdownCheck); err != nil {
+			invalid(fmt.Errorf("invalid shutdownCheck: %v", err))
+		}
+	}
+
+	// Parse idle shutdown configuration; unset or zero disables it
+	var idleShutdownTimeout time.Duration
+	if config.IdleShutdownTimeout != "" {
+		idleShutdownTimeout, err = parseDurationField("idleShutdownTimeout", config.IdleShutdownTimeout)
+		if err != nil {
+			invalid(err)
+		} else if idleShutdownTimeout < 0 {
+			invalid(fmt.Errorf("idleShutdownTimeout must not be negative"))
+		}
+	}
+
+	// Parse keep-alive configuration; unset or zero disables it. Keep-alives end with the idle window so they
+	// never hold the service up past idleShutdownTimeout.
+	var keepAliveInterval time.Duration
+	if config.KeepAliveInterval != "" {
+		keepAliveInterval, err = parseDurationField("keepAliveInterval", config.KeepAliveInterval)
+		if err != nil {
+			invalid(err)
+		} else if keepAliveInterval < 0 {
+			invalid(fmt.Errorf("keepAliveInterval must not be negative"))
+		}
+	}
+	if config.KeepAliveURL != "" {
+		if err := validateCheckURL("keepAliveURL", config.KeepAliveURL); err != nil {
+			invalid(err)
+		}
+	}
+	keepAliveIdleTimeout := defaultKeepAliveIdleTimeout
+	if idleShutdownTimeout > 0 {
+		keepAliveIdleTimeout = idleShutdownTimeout
+	}
+	if config.KeepAliveIdleTimeout != "" {
+		keepAliveIdleTimeout, err = parseDurationField("keepAliveIdleTimeout", config.KeepAliveIdleTimeout)
+		if err != nil {
+			invalid(err)
+		} else if keepAliveIdleTimeout <= 0 {
+			invalid(fmt.Errorf("keepAliveIdleTimeout must be positive"))
+		} else if idleShutdownTimeout > 0 && keepAliveIdleTimeout > idleShutdownTimeout {
+			invalid(fmt.Errorf("keepAliveIdleTimeout must not exceed idleShutdownTimeout"))
+		}
+	}
+
+	// Parse circuit breaker configuration; an unset or zero threshold disables it
+	circuitBreakerThreshold := 0
+	if config.CircuitBreakerThreshold != "" {
+		circuitBreakerThreshold, err = strconv.Atoi(config.CircuitBreakerThreshold)
+		if err != nil {
+			invalid(fmt.Errorf("invalid circuitBreakerThreshold: %v", err))
+		} else if circuitBreakerThreshold < 0 {
+			invalid(fmt.Errorf("circuitBreakerThreshold must not be negative"))
+		}
+	}
+	circuitBreakerWindow := defaultCircuitBreakerWindow
+	if config.CircuitBreakerWindow != "" {
+		circuitBreakerWindow, err = parseDurationField("circuitBreakerWindow", config.CircuitBreakerWindow)
+		if err != nil {
+			invalid(err)
+		} else if circuitBreakerWindow <= 0 {
+			invalid(fmt.Errorf("circuitBreakerWindow must be positive"))
+		}
+	}
+	circuitBreakerCooldown := defaultCircuitBreakerCooldown
+	if config.CircuitBreakerCooldown != "" {
+		circuitBreakerCooldown, err = parseDurationField("circuitBreakerCooldown", config.CircuitBreakerCooldown)
+		if err != nil {
+			invalid(err)
+		} else if circuitBreakerCooldown <= 0 {
+			invalid(fmt.Errorf("circuitBreakerCooldown must be positive"))
+		}
+	}
+
+	maxConcurrentOperations := 1
+	if config.MaxConcurrentOperations != "" {
+		maxConcurrentOperations, err = strconv.Atoi(config.MaxConcurrentOperations)
+		if err != nil {
+			invalid(fmt.Errorf("invalid maxConcurrentOperations: %v", err))
+		} else if maxConcurrentOperations <= 0 {
+			invalid(fmt.Errorf("maxConcurrentOperations must be positive"))
+		}
+		if maxConcurrentOperations <= 0 {
+			maxConcurrentOperations = 1
+		}
+	}
+
+	// Parse the control page template up front so a broken custom template fails at load
+	maintenancePageTmpl, err := template.New("maintenancePage").Parse(maintenancePageTemplate)
+	if err != nil {
+		invalid(fmt.Errorf("invalid maintenance page template: %v", err))
+	}
+
+	controlPageTmpl, err := loadControlPageTemplate(config)
+	if err != nil {
+		invalid(err)
+	}
+
+	if config.ControlPageLogoURL != "" {
+		if _, err := url.Parse(config.ControlPageLogoURL); err != nil {
+			invalid(fmt.Errorf("invalid controlPageLogoURL: %v", err))
+		}
+	}
+
+	// Templates written before the policy existed have no nonces, so custom ones only get a CSP they ask for
+	controlPageCSP := defaultControlPageCSP
+	if config.ControlPageTemplatePath != "" || config.ControlPageTemplateInline != "" {
+		controlPageCSP = ""
+	}
+	if config.ControlPageCSP != "" {
+		controlPageCSP = config.ControlPageCSP
+		if strings.ContainsAny(controlPageCSP, "\r\n") {
+			invalid(fmt.Errorf("invalid controlPageCSP: must be a single line"))
+		}
+		if !config.ControlPageSecurityHeaders {
+			invalid(fmt.Errorf("controlPageCSP requires controlPageSecurityHeaders"))
+		}
+	}
+
+	// Parse scheduled awake windows
+	schedule, err := parseSchedule(config.Schedule)
+	if err != nil {
+		invalid(err)
+	}
+	scheduleLocation := time.Local
+	if config.ScheduleTimezone != "" {
+		scheduleLocation, err = time.LoadLocation(config.ScheduleTimezone)
+		if err != nil {
+			invalid(fmt.Errorf("invalid scheduleTimezone: %v", err))
+		}
+	}
+
+	// Validate power-off configuration if enabled
+	powerOffMethod := strings.ToLower(strings.TrimSpace(config.PowerOffMethod))
+	if powerOffMethod == "" {
+		powerOffMethod = powerOffMethodCommand
+	}
+	var sshHost string
+	var sshRunnerImpl commandRunner
+	switch powerOffMethod {
+	case powerOffMethodCommand:
+		if config.ShowPowerOffButton && config.PowerOffCommand == "" {
+			invalid(fmt.Errorf("powerOffCommand is required when showPowerOffButton is enabled"))
+		}
+	case powerOffMethodSSH:
+		sshHost, err = parseSSHHost(config.SSHHost)
+		if err != nil {
+			invalid(err)
+		}
+		if config.SSHUser == "" {
+			invalid(fmt.Errorf("sshUser is required when powerOffMethod is %q", powerOffMethodSSH))
+		}
+		// Without a pinned key anyone on the path could stand in for the host and receive the command
+		var hostKey []byte
+		if config.SSHHostKey == "" {
+			invalid(fmt.Errorf("sshHostKey is required when powerOffMethod is %q", powerOffMethodSSH))
+		} else {
+			hostKey, err = parseSSHHostKey(config.SSHHostKey)
+			if err != nil {
+				invalid(err)
+			}
+		}
+		if config.SSHKey == "" {
+			invalid(fmt.Errorf("sshKey is required when powerOffMethod is %q", powerOffMethodSSH))
+			break
+		}
+		keyPEM, err := readPEMValue(config.SSHKey)
+		if err != nil {
+			invalid(fmt.Errorf("failed to read sshKey: %v", err))
+			break
+		}
+		signer, err := parseSSHPrivateKey(keyPEM)
+		if err != nil {
+			invalid(fmt.Errorf("invalid sshKey: %v", err))
+			break
+		}
+		sshRunnerImpl = &sshRunner{address: sshHost, user: config.SSHUser, signer: signer, hostKey: hostKey, timeout: powerOffTimeout}
+	default:
+		invalid(fmt.Errorf("invalid powerOffMethod %q: must be %q or %q", config.PowerOffMethod, powerOffMethodCommand, powerOffMethodSSH))
+	}
+	sshCommand := config.SSHCommand
+	if sshCommand == "" {
+		sshCommand = defaultSSHCommand
+	}
+
+	// Set default values for control page settings
+	controlPageTitle := config.ControlPageTitle
+	if controlPageTitle == "" {
+		controlPageTitle = "Service Control"
+	}
+	serviceDescription := config.ServiceDescription
+	if serviceDescription == "" {
+		serviceDescription = "Service"
+	}
+	controlPageTitleTmpl, err := parsePageTextTemplate("controlPageTitle", controlPageTitle)
+	if err != nil {
+		invalid(err)
+	}
+	serviceDescriptionTmpl, err := parsePageTextTemplate("serviceDescription", serviceDescription)
+	if err != nil {
+		invalid(err)
+	}
+
+	plugin := &WOLPlugin{
+		next:                next,
+		name:                name,
+		healthChecks:        healthChecks,
+		healthCheckMode:     healthCheckMode,
+		healthCheckType:     healthCheckType,
+		arpTablePath:        defaultARPTablePath,
+		macAddress:          config.MacAddress,
+		ipAddress:           config.IPAddress,
+		wakeTargetResolveTTL: wakeTargetResolveTTL,
+		broadcastAddresses:  broadcastAddresses,
+		networkInterface:    config.NetworkInterface,
+		wakeTransport:       wakeTransport,
+		wakeMethod:          wakeMethod,
+		gatewayURL:          config.GatewayURL,
+		gatewayToken:        config.GatewayToken,
+		gatewayHeaders:      config.GatewayHeaders,
+		gatewayBody:         gatewayBody,
+		gatewayTimeout:      gatewayTimeout,
+		gatewayClient:       &http.Client{},
+		enableIPv6:          config.EnableIPv6,
+		allowedSubnets:      allowedSubnets,
+		wakeTargetOrder:     wakeTargetOrder,
+		stopOnFirstSuccess:  config.StopOnFirstSuccess,
+		parallelWakeSends:   config.ParallelWakeSends,
+		reuseWakeConnections: config.ReuseWakeConnections,
+		port:                port,
+		sourcePort:          sourcePort,
+		timeout:             timeout,
+		retryAttempts:       retryAttempts,
+		retryInterval:       retryInterval,
+		retryBackoff:        retryBackoff,
+		retryMaxInterval:    retryMaxInterval,
+		wakePollInterval:    wakePollInterval,
+		wakePollMaxInterval: wakePollMaxInterval,
+		wakeHealthyThreshold: wakeHealthyThreshold,
+		autoWakeMode:        autoWakeMode,
+		autoWakeRetryAfter:  autoWakeRetryAfter,
+		maxClientWait:       maxClientWait,
+		coldResponseBody:    config.ColdResponseBody,
+		coldResponseContentType: coldResponseContentType,
+		wakeOnMethods:       wakeOnMethods,
+		nonWakeMethodStatus: nonWakeMethodStatus,
+		preWakeWebhookURL:      config.PreWakeWebhookURL,
+		preWakeWebhookTimeout:  preWakeWebhookTimeout,
+		preWakeWebhookAttempts: preWakeWebhookAttempts,
+		preWakeClient:          &http.Client{},
+		progressSendPct:     progressSendPct,
+		warmHealthCacheOnStart: config.WarmHealthCacheOnStart,
+		progressWaitPct:     progressWaitPct,
+		healthCheckInterval: healthCheckInterval,
+		healthCheckJitter:   healthCheckJitter,
+		jitterRand:          mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
+		healthFlapThreshold: healthFlapThreshold,
+		statusMaxStaleness:  statusMaxStaleness,
+		startupGracePeriod:  startupGracePeriod,
+		startedAt:           now(),
+		healthCheckMethod:          healthCheckMethod,
+		healthCheckBody:            config.HealthCheckBody,
+		healthCheckHeaders:         config.HealthCheckHeaders,
+		forwardHealthAuthHeaders:   forwardHealthAuthHeaders,
+		healthCheckFollowRedirects: config.HealthCheckFollowRedirects,
+		healthCheckMaxRedirects:    healthCheckMaxRedirects,
+		healthCheckClientCert:      healthCheckClientCert,
+		healthCheckGRPCService:     config.HealthCheckGRPCService,
+		readinessCheck:             config.ReadinessCheck,
+		healthCheckUserAgent:       healthCheckUserAgent,
+		healthCheckDisableKeepAlive: config.HealthCheckDisableKeepAlive,
+		healthCheckProxyURL:        healthCheckProxyURL,
+		healthCheckExpectBody:      config.HealthCheckExpectBody,
+		healthCheckExpectBodyRegex: healthCheckExpectBodyRegex,
+		healthCheckMaxLatency:      healthCheckMaxLatency,
+		maxHealthBodyBytes:         maxHealthBodyBytes,
+		packetRepeat:        packetRepeat,
+		packetRepeatDelay:   packetRepeatDelay,
+		packetFormat:        packetFormat,
+		secureOnPassword:    secureOnPassword,
+		debug:               config.Debug,
+		quietWhenHealthy:    config.QuietWhenHealthy,
+		dryRun:              config.DryRun,
+		maintenanceMode:     config.MaintenanceMode,
+		maintenanceMessage:  config.MaintenanceMessage,
+		maintenancePageTmpl: maintenancePageTmpl,
+		enableControlPage:   config.EnableControlPage,
+		controlPageTitle:    controlPageTitle,
+		serviceDescription:  serviceDescription,
+		controlPageTitleTmpl:   controlPageTitleTmpl,
+		serviceDescriptionTmpl: serviceDescriptionTmpl,
+		controlPageTmpl:     controlPageTmpl,
+		controlPageTemplatePath:   config.ControlPageTemplatePath,
+		controlPageTemplateInline: config.ControlPageTemplateInline != "",
+		controlPageCustomCSS: sanitizeCustomCSS(config.ControlPageCustomCSS),
+		controlPageSecurityHeaders: config.ControlPageSecurityHeaders,
+		controlPageCSP:      controlPageCSP,
+		controlPageLogoURL:  config.ControlPageLogoURL,
+		language:            resolveLanguage(config.Language),
+		statusPollIntervalMs: statusPollIntervalMs,
+		statusPollMaxIntervalMs: statusPollMaxIntervalMs,
+		statusPollBackoff:    statusPollBackoff,
+		
+		// Auto-redirect configuration
+		autoRedirect:            config.AutoRedirect,
+		redirectDelay:           redirectDelay,
+		autoRedirectStablePolls: autoRedirectStablePolls,
+		redirectTarget:          config.RedirectTarget,
+		redirectStatusCode:      redirectStatusCode,
+		bypassSessionDuration:   bypassSessionDuration,
+		bypassSessionKey:        bypassSessionKey,
+		skipControlPageWhenHealthy: config.SkipControlPageWhenHealthy,
+		contentNegotiation:      config.ContentNegotiation,
+		
+		// Dashboard configuration
+		showPowerOffButton:  config.ShowPowerOffButton,
+		confirmPowerOff:     config.ConfirmPowerOff,
+		powerOffConfirmMessage: config.PowerOffConfirmMessage,
+		powerOffRequireTyping:  config.PowerOffRequireTyping,
+		hideRedirectButton:  config.HideRedirectButton,
+		showTargetDetails:   config.ShowTargetDetails,
+		enableCSRFProtection: config.EnableCSRFProtection,
+		allowedControlIPs:   allowedControlIPs,
+		readOnlyControlIPs:  readOnlyControlIPs,
+		controlRoleHeader:   http.CanonicalHeaderKey(strings.TrimSpace(config.ControlRoleHeader)),
+		readOnlyRoles:       config.ReadOnlyRoles,
+		adminToken:          config.AdminToken,
+		trustForwardedFor:   config.TrustForwardedFor,
+		
+		// Power-off configuration
+		powerOffMethod:      powerOffMethod,
+		powerOffCommand:     config.PowerOffCommand,
+		sshHost:             sshHost,
+		sshCommand:          sshCommand,
+		sshRunner:           sshRunnerImpl,
+		powerOffDrainPeriod: powerOffDrainPeriod,
+		powerOffTimeout:     powerOffTimeout,
+		shutdownCheck:       config.ShutdownCheck,
+		
+		// Idle shutdown configuration
+		idleShutdownTimeout: idleShutdownTimeout,
+		lastActivity:        now(),
+		
+		// Keep-alive configuration
+		keepAliveInterval:    keepAliveInterval,
+		keepAliveURL:         config.KeepAliveURL,
+		keepAliveIdleTimeout: keepAliveIdleTimeout,
+		
+		// Schedule configuration
+		schedule:            schedule,
+		scheduleLocation:    scheduleLocation,
+		
+		// Tracing configuration
+		enableTracing:       config.EnableTracing,
+		
+		// Circuit breaker configuration
+		circuitBreakerThreshold: circuitBreakerThreshold,
+		circuitBreakerWindow:    circuitBreakerWindow,
+		circuitBreakerCooldown:  circuitBreakerCooldown,
+		breakerState:            breakerClosed,
+		
+		operationSlots:      make(chan struct{}, maxConcurrentOperations),
+		
+		now:                 now,
+		sleep:               sleepContext,
+		interfaces:          netInterfaceProvider{},
+		lookupIP:            lookupIPAddr,
+		healthCache:         &healthStatus{},
+		healthMutex:         sync.RWMutex{},
+		wakeCache:           &wakeStatus{},
+		wakeMutex:           sync.RWMutex{},
+		wakeChanged:         make(chan struct{}),
+		bypassCache:         &bypassStatus{},
+		bypassMutex:         sync.RWMutex{},
+	}
+
+	// Surface a malformed MAC at load time instead of on the first wake attempt
+	if _, err := plugin.parseMACAddress(config.MacAddress); config.MacAddress != "" && err != nil {
+		invalid(fmt.Errorf("invalid macAddress %q: %v", config.MacAddress, err))
+	}
+	plugin.httpClient = plugin.newHealthCheckClient()
+	plugin.grpcTLSConfig = &tls.Config{NextProtos: []string{"h2"}}
+	if healthCheckClientCert != nil {
+		plugin.grpcTLSConfig.Certificates = []tls.Certificate{*healthCheckClientCert}
+	}
+	plugin.sendPacket = plugin.sendToAddress
+	plugin.sendFrame = sendRawFrame
+	if fallbackURL != nil {
+		plugin.fallbackProxy = plugin.newFallbackProxy(fallbackURL)
+	}
+
+	if config.MQTTBroker != "" {
+		brokerAddr, err := parseMQTTBroker(config.MQTTBroker)
+		if err != nil {
+			invalid(err)
+		}
+		clientID := config.MQTTClientID
+		if clientID == "" {
+			clientID = "traefik-wol-" + name
+		}
+		plugin.mqttTopic = config.MQTTTopic
+		if plugin.mqttTopic == "" {
+			plugin.mqttTopic = "traefik-wol/" + name
+		}
+		plugin.publisher = newMQTTClient(brokerAddr, clientID, config.MQTTUsername, config.MQTTPassword)
+	}
+
+	if config.AuditLogPath != "" {
+		// Fail at load rather than losing the first audit records to a bad path
+		if file, err := os.OpenFile(config.AuditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600); err != nil {
+			invalid(fmt.Errorf("invalid auditLogPath: %v", err))
+		} else {
+			file.Close()
+			plugin.auditLogPath = config.AuditLogPath
+		}
+	}
+
+	var otlp *otlpExporter
+	if config.EnableTracing {
+		if config.TracingEndpoint != "" {
+			endpoint, err := parseTracingEndpoint(config.TracingEndpoint)
+			if err != nil {
+				invalid(err)
+			}
+			otlp = newOTLPExporter(name, endpoint)
+			plugin.spanExporter = otlp
+		} else {
+			plugin.spanExporter = &logSpanExporter{name: name}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	plugin.ctx, plugin.cancel = context.WithCancel(ctx)
+	if otlp != nil {
+		go otlp.run(plugin.ctx)
+	}
+	if plugin.publisher != nil {
+		plugin.events = make(chan []byte, eventQueueSize)
+		go plugin.runEventPublisher(plugin.ctx)
+	}
+	if idleShutdownTimeout > 0 {
+		go plugin.runIdleShutdownMonitor(plugin.ctx)
+	}
+	if keepAliveInterval > 0 {
+		go plugin.runKeepAliveMonitor(plugin.ctx)
+	}
+	if len(schedule) > 0 {
+		go plugin.runScheduleMonitor(plugin.ctx)
+	}
+	if plugin.warmHealthCacheOnStart {
+		go plugin.warmHealthCache(plugin.ctx)
+	}
+
+	return plugin, nil
+}
+
+// validateHealthCheckURL ensures the health check URL is absolute with an http(s) scheme and a host
+func validateHealthCheckURL(rawURL string) error {
+	return validateCheckURL("healthCheck", rawURL)
+}
+
+// validateCheckURL ensures the URL configured for field is absolute with an http(s) scheme and a host
+func validateCheckURL(field, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid %s URL %q: %v", field, rawURL, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid %s URL %q: scheme must be http or https", field, rawURL)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("invalid %s URL %q: missing host", field, rawURL)
+	}
+	return nil
+}
+
+// resolveHealthCheckPath joins path onto serviceURL, or onto http://ipAddress when serviceURL is unset,
+// keeping any path prefix serviceURL has
+func resolveHealthCheckPath(path, serviceURL, ipAddress string) (string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("invalid healthCheckPath %q: must start with /", path)
+	}
+	base := serviceURL
+	if base == "" {
+		if ipAddress == "" {
+			return "", fmt.Errorf("healthCheckPath requires serviceURL or ipAddress")
+		}
+		host := ipAddress
+		if ip := net.ParseIP(ipAddress); ip != nil && ip.To4() == nil {
+			host = "[" + ipAddress + "]"
+		}
+		base = "http://" + host
+	}
+	if err := validateCheckURL("serviceURL", base); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(base, "/") + path, nil
+}
+
+// parseHealthCheckMethod normalizes the health check method, defaulting to GET, and ensures a body is only
+// configured for methods that carry one
+func parseHealthCheckMethod(method, body string) (string, error) {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		if body != "" {
+			return "", fmt.Errorf("healthCheckBody is not supported with healthCheckMethod %s", method)
+		}
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return "", fmt.Errorf("invalid healthCheckMethod %q: must be one of GET, HEAD, OPTIONS, POST, PUT or PATCH", method)
+	}
+	return method, nil
+}
+
+// parseDurationField parses a duration config value such as "1m30s", treating a bare integer as seconds
+func parseDurationField(field, value string) (time.Duration, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, nil
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: expected a duration like \"30s\" or a number of seconds", field, value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// controlPageTemplate contains the embedded HTML template for the control page
+const controlPageTemplate = `<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+    <style nonce="{{.CSPNonce}}">
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+        
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', system-ui, sans-serif;
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            padding: 20px;
+        }
+        
+        .container {
+            background: white;
+            border-radius: 20px;
+            box-shadow: 0 20px 60px rgba(0,0,0,0.1);
+            padding: 40px;
+            max-width: 500px;
+            width: 100%;
+            text-align: center;
+        }
+        
+        .service-icon {
+            width: 80px;
+            height: 80px;
+            background: #f0f0f0;
+            border-radius: 50%;
+            margin: 0 auto 20px;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            font-size: 32px;
+            position: relative;
+        }
+        
+        .status-indicator {
+            width: 20px;
+            height: 20px;
+            border-radius: 50%;
+            position: absolute;
+            top: 5px;
+            right: 5px;
+            border: 3px solid white;
+        }
+        
+        .status-down { background: #ff4757; }
+        .status-waking { background: #ffa502; animation: pulse 2s infinite; }
+        .status-up { background: #2ed573; }
+        
+        @keyframes pulse {
+            0%, 100% { opacity: 1; }
+            50% { opacity: 0.5; }
+        }
+        
+        h1 {
+            color: #2c3e50;
+            margin-bottom: 10px;
+            font-size: 28px;
+            font-weight: 700;
+        }
+        
+        .service-name {
+            color: #7f8c8d;
             margin-bottom: 30px;
             font-size: 18px;
         }
         
-        .status-message {
-            background: #f8f9fa;
-            border-radius: 10px;
-            padding: 20px;
-            margin-bottom: 30px;
-            border-left: 4px solid #667eea;
+        .status-message {
+            background: #f8f9fa;
+            border-radius: 10px;
+            padding: 20px;
+            margin-bottom: 30px;
+            border-left: 4px solid #667eea;
+        }
+        
+        .status-text {
+            font-size: 16px;
+            color: #2c3e50;
+            margin-bottom: 10px;
+            font-weight: 500;
+        }
+        
+        .progress-bar {
+            background: #ecf0f1;
+            height: 8px;
+            border-radius: 4px;
+            overflow: hidden;
+            margin-bottom: 10px;
+        }
+        
+        .progress-fill {
+            width: 0%;
+            background: linear-gradient(90deg, #667eea, #764ba2);
+            height: 100%;
+            transition: width 0.3s ease;
+            border-radius: 4px;
+        }
+        
+        .details-text {
+            font-size: 14px;
+            color: #7f8c8d;
+        }
+        
+        .error-text {
+            font-size: 14px;
+            color: #e74c3c;
+            margin-top: 10px;
+        }
+        
+        .target-details {
+            margin-top: 10px;
+            font-family: monospace;
+        }
+        
+        .button-group {
+            display: flex;
+            gap: 15px;
+            justify-content: center;
+            flex-wrap: wrap;
+        }
+        
+        .btn {
+            padding: 15px 30px;
+            border: none;
+            border-radius: 10px;
+            font-size: 16px;
+            font-weight: 600;
+            cursor: pointer;
+            transition: all 0.3s ease;
+            text-decoration: none;
+            display: inline-block;
+            min-width: 160px;
+        }
+        
+        .btn:hover {
+            transform: translateY(-2px);
+            box-shadow: 0 10px 25px rgba(0,0,0,0.15);
+        }
+        
+        .btn:active {
+            transform: translateY(0);
+        }
+        
+        .btn-primary {
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            color: white;
+        }
+        
+        .btn-danger {
+            background: linear-gradient(135deg, #ff4757 0%, #c44569 100%);
+        }
+        
+        .btn-secondary {
+            background: #ecf0f1;
+            color: #2c3e50;
+        }
+        
+        .btn:disabled {
+            opacity: 0.6;
+            cursor: not-allowed;
+            transform: none;
+        }
+        
+        .btn:disabled:hover {
+            transform: none;
+            box-shadow: none;
+        }
+        
+        .hidden {
+            display: none;
+        }
+        
+        .service-logo {
+            width: 100%;
+            height: 100%;
+            object-fit: contain;
+            border-radius: 50%;
+        }
+        
+        @media (max-width: 600px) {
+            .container {
+                margin: 10px;
+                padding: 30px 20px;
+            }
+            
+            .button-group {
+                flex-direction: column;
+            }
+            
+            .btn {
+                min-width: 100%;
+            }
+        }
+    </style>
+    {{if .CustomCSS}}
+    <style id="custom-css" nonce="{{.CSPNonce}}">
+{{.CustomCSS}}
+    </style>
+    {{end}}
+</head>
+<body>
+    <div class="container">
+        <div class="service-icon">
+            {{if .LogoURL}}<img class="service-logo" src="{{.LogoURL}}" alt="{{.ServiceDescription}}">{{else}}🖥️{{end}}
+            <div id="statusIndicator" class="status-indicator status-down"></div>
+        </div>
+        
+        <h1>{{.Title}}</h1>
+        <div class="service-name">{{.ServiceDescription}}</div>
+        
+        <div class="status-message">
+            <div id="statusText" class="status-text">{{.Text.StatusOffline}}</div>
+            <div id="progressContainer" class="hidden">
+                <div class="progress-bar">
+                    <div id="progressFill" class="progress-fill"></div>
+                </div>
+                <div id="progressDetails" class="details-text"></div>
+            </div>
+            <div id="lastError" class="error-text hidden"></div>
+            {{if .TargetMAC}}
+            <div id="targetDetails" class="details-text target-details">MAC {{.TargetMAC}}{{if .TargetIP}} · IP {{.TargetIP}}{{end}}</div>
+            {{end}}
+        </div>
+        
+        <div class="button-group">
+            {{if .ShowWakeButton}}
+            <button id="wakeBtn" class="btn btn-primary">
+                {{.Text.ButtonWake}}
+            </button>
+            {{end}}
+            {{if .ShowPowerOffButton}}
+            <button id="powerOffBtn" class="btn btn-danger">
+                {{.Text.ButtonPowerOff}}
+            </button>
+            {{end}}
+            {{if not .HideRedirectButton}}
+            <button id="redirectBtn" class="btn btn-secondary">
+                {{.Text.ButtonRedirect}}
+            </button>
+            {{end}}
+        </div>
+    </div>
+
+    <script nonce="{{.CSPNonce}}">
+        let isWaking = false;
+        let isPoweringOff = false;
+        let pollTimer;
+        let pollDelayMs;
+        let lastPollState;
+        let eventSource;
+        let autoRedirect = {{.AutoRedirect}};
+        let redirectDelay = {{.RedirectDelaySeconds}};
+        const autoRedirectStablePolls = {{.AutoRedirectStablePolls}};
+        let healthyUpdates = 0;
+        let redirectTimer;
+        const statusPollIntervalMs = {{.StatusPollIntervalMs}};
+        const statusPollMaxIntervalMs = {{.StatusPollMaxIntervalMs}};
+        const statusPollBackoff = {{.StatusPollBackoff}};
+        let confirmPowerOff = {{.ConfirmPowerOff}};
+        const powerOffConfirmMessage = {{.PowerOffConfirmMessage}};
+        const powerOffRequireTyping = {{.PowerOffRequireTyping}};
+        const serviceName = {{.ServiceDescription}};
+        const text = {{.Text}};
+        const csrfToken = {{.CSRFToken}};
+        const originalURL = {{.OriginalURL}};
+        
+        let countdownTimer;
+        
+        // Renders elapsed/remaining time, ticking locally between status updates
+        function showTiming(element, baseText, status) {
+            stopCountdown();
+            if (typeof status.elapsedSeconds !== 'number') {
+                element.textContent = baseText;
+                return;
+            }
+            
+            const receivedAt = Date.now();
+            const render = () => {
+                const passed = Math.floor((Date.now() - receivedAt) / 1000);
+                const elapsed = status.elapsedSeconds + passed;
+                const eta = Math.max(status.etaSeconds - passed, 0);
+                element.textContent = baseText + ' ' + text.detailsTiming
+                    .replace('{elapsed}', elapsed)
+                    .replace('{eta}', eta);
+            };
+            render();
+            countdownTimer = setInterval(render, 1000);
         }
         
-        .status-text {
-            font-size: 16px;
-            color: #2c3e50;
-            margin-bottom: 10px;
-            font-weight: 500;
+        function stopCountdown() {
+            if (countdownTimer) {
+                clearInterval(countdownTimer);
+                countdownTimer = null;
+            }
         }
         
-        .progress-bar {
-            background: #ecf0f1;
-            height: 8px;
-            border-radius: 4px;
-            overflow: hidden;
-            margin-bottom: 10px;
+        // Prefers the server's count of healthy probes in a row, counting updates otherwise
+        function healthyCount(status) {
+            return typeof status.healthyProbes === 'number' ? status.healthyProbes : healthyUpdates;
         }
         
-        .progress-fill {
-            background: linear-gradient(90deg, #667eea, #764ba2);
-            height: 100%;
-            transition: width 0.3s ease;
-            border-radius: 4px;
+        // Healthy, but not for long enough yet to auto-redirect
+        function awaitingStableHealth(status) {
+            return autoRedirect && status.isHealthy && healthyCount(status) < autoRedirectStablePolls;
         }
         
-        .details-text {
-            font-size: 14px;
-            color: #7f8c8d;
+        function updateStatus(status) {
+            if (status.isHealthy) {
+                healthyUpdates++;
+            } else {
+                // The service flapped back down, so the pending redirect would land on a broken page
+                healthyUpdates = 0;
+                if (redirectTimer) {
+                    clearTimeout(redirectTimer);
+                    redirectTimer = null;
+                }
+            }
+            
+            const indicator = document.getElementById('statusIndicator');
+            const statusText = document.getElementById('statusText');
+            const progressContainer = document.getElementById('progressContainer');
+            const progressFill = document.getElementById('progressFill');
+            const progressDetails = document.getElementById('progressDetails');
+            const wakeBtn = document.getElementById('wakeBtn');
+            const powerOffBtn = document.getElementById('powerOffBtn');
+            const lastError = document.getElementById('lastError');
+            
+            if (lastError) {
+                if (status.lastError && !status.isHealthy) {
+                    lastError.textContent = text.statusLastError.replace('{reason}', status.lastError);
+                    lastError.classList.remove('hidden');
+                } else {
+                    lastError.classList.add('hidden');
+                }
+            }
+            
+            indicator.className = 'status-indicator ' + 
+                (status.isHealthy ? 'status-up' : 
+                 status.isWaking ? 'status-waking' : 'status-down');
+            
+            if (!status.isWaking && !status.isPoweringOff) {
+                stopCountdown();
+            }
+            
+            if (status.isHealthy) {
+                statusText.textContent = text.statusOnline;
+                progressContainer.classList.add('hidden');
+                if (wakeBtn) {
+                    wakeBtn.disabled = true;
+                    wakeBtn.textContent = text.buttonOnline;
+                }
+                if (powerOffBtn) {
+                    powerOffBtn.disabled = false;
+                    powerOffBtn.textContent = text.buttonPowerOff;
+                }
+                
+                // Auto-redirect if enabled, once the service has stayed healthy long enough
+                if (autoRedirect && !redirectTimer && healthyCount(status) >= autoRedirectStablePolls) {
+                    redirectTimer = setTimeout(() => {
+                        goToService();
+                    }, redirectDelay * 1000);
+                }
+                if (redirectTimer) {
+                    statusText.textContent = text.statusRedirecting.replace('{seconds}', redirectDelay);
+                }
+            } else if (status.isWaking) {
+                statusText.textContent = status.message || text.statusWaking;
+                progressContainer.classList.remove('hidden');
+                
+                progressFill.style.width = (status.progress || 0) + '%';
+                showTiming(progressDetails, text.detailsWaking, status);
+                
+                if (wakeBtn) {
+                    wakeBtn.disabled = true;
+                    wakeBtn.textContent = text.buttonWaking;
+                }
+                if (powerOffBtn) {
+                    powerOffBtn.disabled = true;
+                    powerOffBtn.textContent = text.buttonPowerOff;
+                }
+            } else if (status.isPoweringOff) {
+                statusText.textContent = status.message || text.statusPoweringOff;
+                progressContainer.classList.remove('hidden');
+                
+                progressFill.style.width = (status.progress || 0) + '%';
+                showTiming(progressDetails, text.detailsPoweringOff, status);
+                
+                if (wakeBtn) {
+                    wakeBtn.disabled = true;
+                    wakeBtn.textContent = text.buttonWake;
+                }
+                if (powerOffBtn) {
+                    powerOffBtn.disabled = true;
+                    powerOffBtn.textContent = text.buttonPoweringOff;
+                }
+            } else {
+                statusText.textContent = status.message || text.statusOffline;
+                progressContainer.classList.add('hidden');
+                if (wakeBtn) {
+                    wakeBtn.disabled = false;
+                    wakeBtn.textContent = text.buttonWake;
+                }
+                if (powerOffBtn) {
+                    powerOffBtn.disabled = false;
+                    powerOffBtn.textContent = text.buttonPowerOff;
+                }
+                isWaking = false;
+                isPoweringOff = false;
+                if (pollTimer) {
+                    clearTimeout(pollTimer);
+                    pollTimer = null;
+                }
+            }
+        }
+        
+        function wakeService() {
+            if (isWaking || isPoweringOff) return;
+            
+            isWaking = true;
+            
+            fetch('/_wol/wake', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-WOL-CSRF-Token': csrfToken
+                }
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.success) {
+                    pollStatus();
+                } else {
+                    updateStatus({
+                        isHealthy: false,
+                        isWaking: false,
+                        message: data.message || text.errorWakeFailed
+                    });
+                }
+            })
+            .catch(err => {
+                updateStatus({
+                    isHealthy: false,
+                    isWaking: false,
+                    message: text.errorWakeRequest
+                });
+            });
+        }
+        
+        function powerOffService() {
+            if (isWaking || isPoweringOff) return;
+            
+            if (powerOffRequireTyping) {
+                // Typing the service name replaces the simple confirm dialog
+                const typed = prompt(powerOffConfirmMessage + '\n\n' + text.confirmTypeName.replace('{name}', serviceName));
+                if (typed === null) return;
+                if (typed.trim() !== serviceName) {
+                    alert(text.confirmTypeMismatch);
+                    return;
+                }
+            } else if (confirmPowerOff && !confirm(powerOffConfirmMessage)) {
+                return;
+            }
+            
+            isPoweringOff = true;
+            
+            fetch('/_wol/poweroff', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-WOL-CSRF-Token': csrfToken
+                }
+            })
+            .then(response => response.json())
+            .then(data => {
+                if (data.success) {
+                    pollStatus();
+                } else {
+                    updateStatus({
+                        isHealthy: false,
+                        isPoweringOff: false,
+                        message: data.message || text.errorPowerOffFailed
+                    });
+                }
+            })
+            .catch(err => {
+                updateStatus({
+                    isHealthy: false,
+                    isPoweringOff: false,
+                    message: text.errorPowerOffRequest
+                });
+            });
+        }
+        
+        function isStatusFinal(data) {
+            if (awaitingStableHealth(data)) return false;
+            return data.isHealthy || (!data.isWaking && !data.isPoweringOff);
+        }
+        
+        function pollStatus() {
+            if (pollTimer) clearTimeout(pollTimer);
+            if (eventSource) eventSource.close();
+            
+            // Prefer streamed updates, falling back to interval polling
+            if (window.EventSource) {
+                eventSource = new EventSource('/_wol/events');
+                eventSource.onmessage = (event) => {
+                    const data = JSON.parse(event.data);
+                    updateStatus(data);
+                    // Events only arrive on changes, so stable health is confirmed by polling
+                    if (isStatusFinal(data) || awaitingStableHealth(data)) {
+                        eventSource.close();
+                        eventSource = null;
+                        if (awaitingStableHealth(data)) startPolling();
+                    }
+                };
+                eventSource.onerror = () => {
+                    eventSource.close();
+                    eventSource = null;
+                    startPolling();
+                };
+                return;
+            }
+            
+            startPolling();
+        }
+        
+        function startPolling() {
+            if (pollTimer) clearTimeout(pollTimer);
+            pollDelayMs = statusPollIntervalMs;
+            lastPollState = undefined;
+            schedulePoll();
+        }
+        
+        function schedulePoll() {
+            // Spread polls from many open pages once they start backing off
+            let delay = pollDelayMs;
+            if (statusPollBackoff > 1) {
+                delay = delay * (0.9 + Math.random() * 0.2);
+            }
+            pollTimer = setTimeout(() => {
+                fetch('/_wol/status')
+                .then(response => response.json())
+                .then(data => {
+                    updateStatus(data);
+                    if (isStatusFinal(data)) {
+                        pollTimer = null;
+                        return;
+                    }
+                    // Poll less often the longer nothing changes, starting over when the state does
+                    const state = [data.isHealthy, data.isWaking, data.isPoweringOff].join();
+                    if (state !== lastPollState) {
+                        lastPollState = state;
+                        pollDelayMs = statusPollIntervalMs;
+                    } else {
+                        pollDelayMs = Math.min(pollDelayMs * statusPollBackoff, statusPollMaxIntervalMs);
+                    }
+                    schedulePoll();
+                })
+                .catch(err => {
+                    console.error('Error polling status:', err);
+                    schedulePoll();
+                });
+            }, delay);
+        }
+        
+        function goToService() {
+            // Create and submit POST form to redirect endpoint
+            const form = document.createElement('form');
+            form.method = 'POST';
+            form.action = '/_wol/redirect';
+            form.style.display = 'none';
+            const tokenField = document.createElement('input');
+            tokenField.type = 'hidden';
+            tokenField.name = 'csrf_token';
+            tokenField.value = csrfToken;
+            form.appendChild(tokenField);
+            const originalField = document.createElement('input');
+            originalField.type = 'hidden';
+            originalField.name = 'original_url';
+            originalField.value = originalURL;
+            form.appendChild(originalField);
+            document.body.appendChild(form);
+            form.submit();
+        }
+        
+        // Handlers are attached here rather than inline so the page works under a nonce-based CSP
+        [['wakeBtn', wakeService], ['powerOffBtn', powerOffService], ['redirectBtn', goToService]].forEach(([id, handler]) => {
+            const button = document.getElementById(id);
+            if (button) button.addEventListener('click', () => handler());
+        });
+        
+        // Initial status check
+        fetch('/_wol/status')
+        .then(response => response.json())
+        .then(data => {
+            updateStatus(data);
+            if (awaitingStableHealth(data)) startPolling();
+        })
+        .catch(err => console.error('Error getting initial status:', err));
+    </script>
+</body>
+</html>`
+
+// maintenancePageTemplate is served instead of the service and the control page while maintenanceMode is on
+const maintenancePageTemplate = `<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
         }
         
-        .button-group {
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', system-ui, sans-serif;
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            min-height: 100vh;
             display: flex;
-            gap: 15px;
+            align-items: center;
             justify-content: center;
-            flex-wrap: wrap;
-        }
-        
-        .btn {
-            padding: 15px 30px;
-            border: none;
-            border-radius: 10px;
-            font-size: 16px;
-            font-weight: 600;
-            cursor: pointer;
-            transition: all 0.3s ease;
-            text-decoration: none;
-            display: inline-block;
-            min-width: 160px;
+            padding: 20px;
         }
         
-        .btn:hover {
-            transform: translateY(-2px);
-            box-shadow: 0 10px 25px rgba(0,0,0,0.15);
+        .container {
+            background: white;
+            border-radius: 20px;
+            box-shadow: 0 20px 60px rgba(0,0,0,0.1);
+            padding: 40px;
+            max-width: 500px;
+            width: 100%;
+            text-align: center;
         }
         
-        .btn:active {
-            transform: translateY(0);
+        .service-icon {
+            width: 80px;
+            height: 80px;
+            background: #f0f0f0;
+            border-radius: 50%;
+            margin: 0 auto 20px;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            font-size: 32px;
         }
         
-        .btn-primary {
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            color: white;
+        .service-logo {
+            width: 100%;
+            height: 100%;
+            object-fit: contain;
+            border-radius: 50%;
         }
         
-        .btn-secondary {
-            background: #ecf0f1;
+        h1 {
             color: #2c3e50;
+            margin-bottom: 10px;
+            font-size: 28px;
+            font-weight: 700;
         }
         
-        .btn:disabled {
-            opacity: 0.6;
-            cursor: not-allowed;
-            transform: none;
-        }
-        
-        .btn:disabled:hover {
-            transform: none;
-            box-shadow: none;
-        }
-        
-        .hidden {
-            display: none;
+        .service-name {
+            color: #7f8c8d;
+            margin-bottom: 30px;
+            font-size: 18px;
         }
         
-        @media (max-width: 600px) {
-            .container {
-                margin: 10px;
-                padding: 30px 20px;
-            }
-            
-            .button-group {
-                flex-direction: column;
-            }
-            
-            .btn {
-                min-width: 100%;
-            }
+        .maintenance-message {
+            background: #f8f9fa;
+            border-radius: 10px;
+            padding: 20px;
+            color: #2c3e50;
+            font-size: 16px;
         }
     </style>
+    {{if .CustomCSS}}
+    <style id="custom-css">
+{{.CustomCSS}}
+    </style>
+    {{end}}
 </head>
 <body>
     <div class="container">
-        <div class="service-icon" style="position: relative;">
-            🖥️
-            <div id="statusIndicator" class="status-indicator status-down"></div>
+        <div class="service-icon">
+            {{if .LogoURL}}<img class="service-logo" src="{{.LogoURL}}" alt="{{.ServiceDescription}}">{{else}}🔧{{end}}
         </div>
-        
-        <h1>{{.Title}}</h1>
+        <h1>{{.Text.MaintenanceHeading}}</h1>
         <div class="service-name">{{.ServiceDescription}}</div>
-        
-        <div class="status-message">
-            <div id="statusText" class="status-text">Service is currently offline</div>
-            <div id="progressContainer" class="hidden">
-                <div class="progress-bar">
-                    <div id="progressFill" class="progress-fill" style="width: 0%"></div>
-                </div>
-                <div id="progressDetails" class="details-text"></div>
-            </div>
-        </div>
-        
-        <div class="button-group">
-            <button id="wakeBtn" class="btn btn-primary" onclick="wakeService()">
-                🚀 Turn On Service
-            </button>
-            {{if .ShowPowerOffButton}}
-            <button id="powerOffBtn" class="btn btn-danger" onclick="powerOffService()" style="background: linear-gradient(135deg, #ff4757 0%, #c44569 100%);">
-                ⏻ Power Off
-            </button>
-            {{end}}
-            {{if not .HideRedirectButton}}
-            <button id="redirectBtn" class="btn btn-secondary" onclick="goToService()">
-                ↗️ Go to Service
-            </button>
-            {{end}}
-        </div>
+        <div id="maintenanceMessage" class="maintenance-message">{{.Message}}</div>
     </div>
+</body>
+</html>`
+
+// ServeHTTP implements the http.Handler interface.
+func (w *WOLPlugin) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	// Handle control page endpoints
+	if strings.HasPrefix(req.URL.Path, "/_wol/") {
+		if req.Method == http.MethodPost && !w.controlIPAllowed(req) {
+			if w.debug {
+				fmt.Printf("WOL Plugin [%s]: Rejected %s from %s, not in allowedControlIPs\n", w.name, req.URL.Path, w.clientIP(req))
+			}
+			w.writeOperationError(rw, errIPNotAllowed)
+			return
+		}
+		if req.Method == http.MethodPost && w.isReadOnlyClient(req) {
+			switch req.URL.Path {
+			case "/_wol/wake", "/_wol/poweroff", "/_wol/cancel":
+				if w.debug {
+					fmt.Printf("WOL Plugin [%s]: Rejected %s from read-only client %s\n", w.name, req.URL.Path, w.clientIP(req))
+				}
+				w.writeOperationError(rw, errReadOnly)
+				return
+			}
+		}
+
+		switch req.URL.Path {
+		case "/_wol/wake":
+			w.handleWakeEndpoint(rw, req)
+			return
+		case "/_wol/poweroff":
+			w.handlePowerOffEndpoint(rw, req)
+			return
+		case "/_wol/status":
+			w.handleStatusEndpoint(rw, req)
+			return
+		case "/_wol/events":
+			w.handleEventsEndpoint(rw, req)
+			return
+		case "/_wol/ws":
+			w.handleWebSocketEndpoint(rw, req)
+			return
+		case "/_wol/cancel":
+			w.handleCancelEndpoint(rw, req)
+			return
+		case "/_wol/health":
+			w.handleHealthEndpoint(rw, req)
+			return
+		case "/_wol/version":
+			w.handleVersionEndpoint(rw, req)
+			return
+		case "/_wol/diagnostics":
+			w.handleDiagnosticsEndpoint(rw, req)
+			return
+		case "/_wol/config":
+			w.handleConfigEndpoint(rw, req)
+			return
+		case "/_wol/redirect":
+			w.handleRedirectEndpoint(rw, req)
+			return
+		case "/_wol/admin/poweroff":
+			w.handleAdminPowerOffEndpoint(rw, req)
+			return
+		case "/_wol/stats":
+			w.handleStatsEndpoint(rw, req)
+			return
+		case "/_wol/admin/stats/reset":
+			w.handleAdminStatsResetEndpoint(rw, req)
+			return
+		case "/_wol/reset":
+			w.handleResetEndpoint(rw, req)
+			return
+		}
+	}
+
+	// A power-off is draining: turn new requests away so load balancers stop sending them
+	if w.isDraining() {
+		rw.Header().Set("Retry-After", retryAfterSeconds(w.powerOffDrainPeriod))
+		rw.Header().Set("Connection", "close")
+		http.Error(rw, "Service is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Maintenance keeps the service down: answer every request with the maintenance page
+	if w.maintenanceMode {
+		// Keep the health cache current so /_wol/status and /_wol/health still report the real state
+		w.getCachedHealthStatus()
+		w.serveMaintenancePage(rw, req)
+		return
+	}
+
+	// Let everything settle after startup before making wake decisions
+	if w.inStartupGracePeriod() {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Within startup grace period, forwarding without a health check\n", w.name)
+		}
+		w.serveNext(rw, req)
+		return
+	}
+
+	// Check for bypass state first (handles "Go to Service" functionality)
+	if w.isBypassActive(req) {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Bypass state active, forwarding to service\n", w.name)
+		}
+		// Clear bypass state after use; a session cookie keeps working and leaves it for the client that set it
+		if !w.validBypassSession(req) {
+			w.clearBypassState()
+		}
+		w.serveNext(rw, req)
+		return
+	}
+
+	// With a fallback, cold requests are served by it while the service wakes in the background
+	if w.fallbackProxy != nil {
+		if w.requestHealthStatus(req) {
+			w.serveHealthy(rw, req)
+			return
+		}
+		if !w.wakeMethodAllowed(req) {
+			w.serveNonWakeMethod(rw, req)
+			return
+		}
+		w.serveFallback(rw, req)
+		return
+	}
+
+	// Check if control page is enabled
+	if w.enableControlPage {
+		
+		isHealthy := w.requestHealthStatus(req)
+		if !isHealthy && !w.wakeMethodAllowed(req) {
+			w.serveNonWakeMethod(rw, req)
+			return
+		}
+		
+		// Show control page unless configured to skip when healthy
+		if !isHealthy || !w.skipControlPageWhenHealthy {
+			w.serveControlPage(rw, req)
+			return
+		}
+		
+		// Service is healthy and we're configured to skip control page
+		w.serveHealthy(rw, req)
+		return
+	}
+
+	// Control page disabled - use original auto-wake behavior
+	isHealthy := w.requestHealthStatus(req)
+	if !isHealthy {
+		if !w.wakeMethodAllowed(req) {
+			w.serveNonWakeMethod(rw, req)
+			return
+		}
+		w.performAutoWake(rw, req)
+		return
+	}
+
+	w.serveHealthy(rw, req)
+}
+
+// wakeMethodAllowed reports whether req's method may wake the service or see the control page
+func (w *WOLPlugin) wakeMethodAllowed(req *http.Request) bool {
+	return w.wakeOnMethods == nil || w.wakeOnMethods[req.Method]
+}
+
+// serveNonWakeMethod turns away a request to the cold service whose method is not in wakeOnMethods
+func (w *WOLPlugin) serveNonWakeMethod(rw http.ResponseWriter, req *http.Request) {
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: Service unhealthy, not waking for %s %s\n", w.name, req.Method, req.URL.Path)
+	}
+	http.Error(rw, "Service is unavailable", w.nonWakeMethodStatus)
+}
+
+// serveHealthy forwards a request that found the service healthy, counting it under quietWhenHealthy
+func (w *WOLPlugin) serveHealthy(rw http.ResponseWriter, req *http.Request) {
+	if w.quietWhenHealthy {
+		w.activityMutex.Lock()
+		w.quietRequests++
+		w.activityMutex.Unlock()
+	}
+	w.serveNext(rw, req)
+}
+
+// inStartupGracePeriod reports whether the plugin started less than startupGracePeriod ago
+func (w *WOLPlugin) inStartupGracePeriod() bool {
+	return w.startupGracePeriod > 0 && w.now().Sub(w.startedAt) < w.startupGracePeriod
+}
+
+// serveNext forwards the request to the protected service, recording it as activity
+func (w *WOLPlugin) serveNext(rw http.ResponseWriter, req *http.Request) {
+	w.recordActivity()
+	w.next.ServeHTTP(rw, req)
+}
+
+// getCachedHealthStatus returns cached health status or performs new check if cache expired
+func (w *WOLPlugin) getCachedHealthStatus() bool {
+	w.healthMutex.RLock()
+	cache := w.healthCache
+	now := w.now()
+	
+	// Check if cache is valid
+	if now.Sub(cache.lastCheck) < cache.interval {
+		isHealthy := cache.isHealthy
+		w.healthMutex.RUnlock()
+		return isHealthy
+	}
+	w.healthMutex.RUnlock()
+
+	// Cache expired, perform new health check
+	return w.sharedHealthCheck(true)
+}
+
+// statusHealthStatus returns the health reported by the status endpoints. A cache that expired less than
+// statusMaxStaleness ago is served as is while a background refresh updates it, so polls never wait on a probe.
+func (w *WOLPlugin) statusHealthStatus() bool {
+	if w.statusMaxStaleness > 0 {
+		w.healthMutex.RLock()
+		cache := *w.healthCache
+		w.healthMutex.RUnlock()
+
+		age := w.now().Sub(cache.lastCheck)
+		if !cache.lastCheck.IsZero() && age >= cache.interval && age < cache.interval+w.statusMaxStaleness {
+			w.refreshHealthInBackground()
+			return cache.isHealthy
+		}
+	}
+	return w.getCachedHealthStatus()
+}
+
+// refreshHealthInBackground starts a health check without waiting for it, unless one is already running
+func (w *WOLPlugin) refreshHealthInBackground() {
+	w.healthFlightMutex.Lock()
+	if w.healthFlight != nil || w.healthRefreshing {
+		w.healthFlightMutex.Unlock()
+		return
+	}
+	w.healthRefreshing = true
+	w.healthFlightMutex.Unlock()
+
+	go func() {
+		w.sharedHealthCheck(true)
+		w.healthFlightMutex.Lock()
+		w.healthRefreshing = false
+		w.healthFlightMutex.Unlock()
+	}()
+}
+
+// requestHealthStatus is getCachedHealthStatus for a request on the cold path. With forwardHealthAuthFromRequest,
+// a probe it triggers carries the request's allowlisted headers, so a health endpoint behind the same
+// forward-auth as the service sees the user's credentials. A request carrying none of them never probes.
+func (w *WOLPlugin) requestHealthStatus(req *http.Request) bool {
+	if len(w.forwardHealthAuthHeaders) == 0 {
+		return w.getCachedHealthStatus()
+	}
+
+	w.healthMutex.RLock()
+	if w.now().Sub(w.healthCache.lastCheck) < w.healthCache.interval {
+		isHealthy := w.healthCache.isHealthy
+		w.healthMutex.RUnlock()
+		return isHealthy
+	}
+	w.healthMutex.RUnlock()
+
+	return w.sharedHealthCheckWithHeaders(true, w.forwardedHealthHeaders(req))
+}
+
+// forwardedHealthHeaders returns the headers of req that forwardHealthAuthFromRequest copies onto health
+// probes, or nil if forwarding is off or req carries none of them
+func (w *WOLPlugin) forwardedHealthHeaders(req *http.Request) http.Header {
+	var forwarded http.Header
+	for _, name := range w.forwardHealthAuthHeaders {
+		if values := req.Header.Values(name); len(values) > 0 {
+			if forwarded == nil {
+				forwarded = make(http.Header)
+			}
+			forwarded[name] = values
+		}
+	}
+	return forwarded
+}
+
+type healthHeadersKey struct{}
+
+// contextWithHealthHeaders carries the forwarded health probe headers of the request that started a wake
+// into its sequence, so the probes confirming the wake authenticate the same way
+func contextWithHealthHeaders(ctx context.Context, forwarded http.Header) context.Context {
+	if len(forwarded) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, healthHeadersKey{}, forwarded)
+}
+
+// healthHeadersFromContext returns the headers carried by ctx, or nil
+func healthHeadersFromContext(ctx context.Context) http.Header {
+	forwarded, _ := ctx.Value(healthHeadersKey{}).(http.Header)
+	return forwarded
+}
+
+// defaultForwardHealthAuthHeaders are the request headers forwarded when forwardHealthAuthHeaders is unset
+var defaultForwardHealthAuthHeaders = []string{"Authorization", "Cookie"}
+
+// parseForwardHealthAuthHeaders canonicalizes the forwardHealthAuthHeaders allowlist
+func parseForwardHealthAuthHeaders(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return defaultForwardHealthAuthHeaders, nil
+	}
+	headers := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.ContainsAny(name, " \t:") {
+			return nil, fmt.Errorf("invalid forwardHealthAuthHeaders entry %q", name)
+		}
+		headers = append(headers, http.CanonicalHeaderKey(name))
+	}
+	return headers, nil
+}
+
+// refreshHealthStatus performs a live health check regardless of the cache and stores the result
+func (w *WOLPlugin) refreshHealthStatus() bool {
+	return w.sharedHealthCheck(false)
+}
+
+// warmHealthCache runs one health check right after startup so the first request finds a cached result.
+// A request arriving while it runs shares the probe instead of starting another.
+func (w *WOLPlugin) warmHealthCache(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	healthy := w.refreshHealthStatus()
+	if ctx.Err() != nil {
+		// The middleware was torn down while probing; nobody will read the result
+		return
+	}
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: Health cache warmed, service healthy: %v\n", w.name, healthy)
+	}
+}
+
+// healthCall is a performHealthCheck in flight whose result is shared by every caller waiting on it
+type healthCall struct {
+	done   chan struct{}
+	result bool
+}
+
+// sharedHealthCheck probes the service and records the result in the health cache. Callers arriving while a
+// probe is in flight wait for it and share its result instead of starting another. With useCache, a result
+// recorded by a probe that finished since the caller last read the cache is returned without probing.
+func (w *WOLPlugin) sharedHealthCheck(useCache bool) bool {
+	return w.sharedHealthCheckWithHeaders(useCache, nil)
+}
+
+// sharedHealthCheckWithHeaders is sharedHealthCheck with extra headers on the probe, if it starts one.
+// Callers joining a probe already in flight share its result, whatever headers it carried, and the result
+// is recorded in the health cache every caller reads. With forwardHealthAuthFromRequest, a probe without
+// forwarded headers cannot tell whether the service is up, so it is kept out of both: cache readers get the
+// last recorded result without probing, and refreshes probe on their own and only learn the result themselves.
+func (w *WOLPlugin) sharedHealthCheckWithHeaders(useCache bool, forwarded http.Header) bool {
+	if len(w.forwardHealthAuthHeaders) > 0 && len(forwarded) == 0 {
+		if useCache {
+			w.healthMutex.RLock()
+			defer w.healthMutex.RUnlock()
+			return w.healthCache.isHealthy
+		}
+		return w.performHealthCheckWithHeaders(nil)
+	}
+
+	w.healthFlightMutex.Lock()
+	if call := w.healthFlight; call != nil {
+		w.healthFlightMutex.Unlock()
+		<-call.done
+		return call.result
+	}
+	if useCache {
+		w.healthMutex.RLock()
+		if w.now().Sub(w.healthCache.lastCheck) < w.healthCache.interval {
+			isHealthy := w.healthCache.isHealthy
+			w.healthMutex.RUnlock()
+			w.healthFlightMutex.Unlock()
+			return isHealthy
+		}
+		w.healthMutex.RUnlock()
+	}
+	call := &healthCall{done: make(chan struct{})}
+	w.healthFlight = call
+	w.healthFlightMutex.Unlock()
+
+	now := w.now()
+	healthy := w.performHealthCheckWithHeaders(forwarded)
+
+	// Record before clearing the flight so later callers find the fresh result in the cache
+	w.healthMutex.Lock()
+	call.result = w.recordHealthLocked(now, healthy)
+	w.healthMutex.Unlock()
+
+	w.healthFlightMutex.Lock()
+	w.healthFlight = nil
+	w.healthFlightMutex.Unlock()
+	close(call.done)
+	return call.result
+}
+
+// recordHealthLocked stores a health check result and returns the resulting cached state. After the first
+// result the state only changes once healthFlapThreshold consecutive checks disagree with it.
+// The caller must hold healthMutex for writing.
+func (w *WOLPlugin) recordHealthLocked(now time.Time, newHealth bool) bool {
+	if newHealth {
+		w.healthCache.healthyProbes++
+	} else {
+		w.healthCache.healthyProbes = 0
+	}
+	if !w.healthCache.lastCheck.IsZero() && newHealth != w.healthCache.isHealthy {
+		w.healthCache.flapCount++
+		if w.healthCache.flapCount < w.healthFlapThreshold {
+			if w.debug {
+				fmt.Printf("WOL Plugin [%s]: Health check returned %v, keeping %v (%d/%d)\n", w.name, newHealth, w.healthCache.isHealthy, w.healthCache.flapCount, w.healthFlapThreshold)
+			}
+			newHealth = w.healthCache.isHealthy
+		} else {
+			w.healthCache.flapCount = 0
+		}
+	} else {
+		w.healthCache.flapCount = 0
+	}
+
+	if w.healthCache.lastState != newHealth || w.healthCache.lastCheck.IsZero() {
+		w.publishEvent("health_changed", map[string]interface{}{"isHealthy": newHealth})
+	}
+	
+	// Log only on state changes or debug mode; quietWhenHealthy drops the steady-state healthy lines
+	changed := w.healthCache.lastState != newHealth
+	if changed || w.debug {
+		logChange := w.debug || w.healthCache.lastCheck.IsZero()
+		if w.quietWhenHealthy {
+			logChange = changed || w.healthCache.lastCheck.IsZero() || (w.debug && !newHealth)
+		}
+		if logChange {
+			fmt.Printf("WOL Plugin [%s]: Health status changed to %v for %s\n", w.name, newHealth, strings.Join(w.healthChecks, ", "))
+		}
+		w.healthCache.lastState = newHealth
+	}
+	
+	w.healthCache.isHealthy = newHealth
+	w.healthCache.lastCheck = now
+	w.healthCache.interval = w.jitteredHealthCheckInterval()
+	w.recordAvailability(now, newHealth)
+	return newHealth
+}
+
+// recordAvailability updates the uptime statistics with a health result observed at now
+func (w *WOLPlugin) recordAvailability(now time.Time, healthy bool) {
+	w.statsMutex.Lock()
+	defer w.statsMutex.Unlock()
+
+	if w.stats.observedSince.IsZero() {
+		w.stats = availabilityStats{observedSince: now, isHealthy: healthy, since: now}
+		return
+	}
+	if healthy == w.stats.isHealthy {
+		return
+	}
+	if w.stats.isHealthy {
+		w.stats.healthyTotal += now.Sub(w.stats.since)
+		w.stats.outages++
+	} else {
+		w.stats.unhealthyTotal += now.Sub(w.stats.since)
+	}
+	w.stats.isHealthy = healthy
+	w.stats.since = now
+	w.stats.lastTransition = now
+}
+
+// availabilitySnapshot returns the statistics with the current period counted up to now
+func (w *WOLPlugin) availabilitySnapshot() availabilityStats {
+	w.statsMutex.Lock()
+	stats := w.stats
+	w.statsMutex.Unlock()
+
+	if !stats.observedSince.IsZero() {
+		if stats.isHealthy {
+			stats.healthyTotal += w.now().Sub(stats.since)
+		} else {
+			stats.unhealthyTotal += w.now().Sub(stats.since)
+		}
+	}
+	return stats
+}
+
+// jitteredHealthCheckInterval returns healthCheckInterval plus a random extra of up to healthCheckJitter, so
+// instances started together drift apart instead of probing in lockstep; the caller must hold healthMutex
+func (w *WOLPlugin) jitteredHealthCheckInterval() time.Duration {
+	if w.healthCheckJitter <= 0 || w.jitterRand == nil {
+		return w.healthCheckInterval
+	}
+	return w.healthCheckInterval + time.Duration(w.jitterRand.Int63n(int64(w.healthCheckJitter)+1))
+}
+
+// parseHealthCheckJitter accepts either a fraction of healthCheckInterval such as "0.2" or a maximum
+// duration such as "2s"; empty means no jitter
+func parseHealthCheckJitter(value string, interval time.Duration) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if fraction, err := strconv.ParseFloat(value, 64); err == nil {
+		if fraction < 0 || fraction > 1 {
+			return 0, fmt.Errorf("invalid healthCheckJitter %q: fraction must be between 0 and 1", value)
+		}
+		return time.Duration(fraction * float64(interval)), nil
+	}
+	jitter, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid healthCheckJitter %q: expected a fraction like \"0.2\" or a duration like \"2s\"", value)
+	}
+	if jitter < 0 {
+		return 0, fmt.Errorf("healthCheckJitter must not be negative")
+	}
+	return jitter, nil
+}
+
+// isBypassActive checks if bypass state is active and not expired, or if req carries a valid bypass session
+// cookie
+func (w *WOLPlugin) isBypassActive(req *http.Request) bool {
+	if w.validBypassSession(req) {
+		return true
+	}
+
+	w.bypassMutex.RLock()
+	defer w.bypassMutex.RUnlock()
+	
+	if !w.bypassCache.isBypass {
+		return false
+	}
+	
+	// Check if bypass has expired (5 second timeout)
+	if w.now().Sub(w.bypassCache.startTime) > 5*time.Second {
+		return false
+	}
+	
+	return true
+}
+
+// clearBypassState clears the bypass state
+func (w *WOLPlugin) clearBypassState() {
+	w.bypassMutex.Lock()
+	defer w.bypassMutex.Unlock()
+	
+	w.bypassCache.isBypass = false
+	w.bypassCache.startTime = time.Time{}
+}
+
+// newHealthCheckClient creates the shared health check HTTP client with connection pooling
+func (w *WOLPlugin) newHealthCheckClient() *http.Client {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        10,
+			MaxIdleConnsPerHost: 5,
+			IdleConnTimeout:     30 * time.Second,
+			DisableKeepAlives:   w.healthCheckDisableKeepAlive,
+		},
+	}
+	if w.healthCheckProxyURL != nil {
+		client.Transport.(*http.Transport).Proxy = http.ProxyURL(w.healthCheckProxyURL)
+	}
+	if w.healthCheckClientCert != nil {
+		client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{
+			Certificates: []tls.Certificate{*w.healthCheckClientCert},
+		}
+	}
+	if !w.healthCheckFollowRedirects {
+		// Evaluate the redirect response itself rather than wherever it points
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	} else if w.healthCheckMaxRedirects > 0 {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) > w.healthCheckMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", w.healthCheckMaxRedirects)
+			}
+			return nil
+		}
+	}
+	return client
+}
+
+// parseProxyURL parses an http, https or socks5 proxy address such as "http://proxy.internal:3128"
+func parseProxyURL(value string) (*url.URL, error) {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q: must be http, https or socks5", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("missing host in %q", value)
+	}
+	return parsed, nil
+}
+
+// loadClientCertificate loads the health check client certificate pair, each given as a file path or inline PEM
+func loadClientCertificate(certValue, keyValue string) (*tls.Certificate, error) {
+	if certValue == "" && keyValue == "" {
+		return nil, nil
+	}
+	if certValue == "" || keyValue == "" {
+		return nil, fmt.Errorf("healthCheckClientCert and healthCheckClientKey must be set together")
+	}
+
+	certPEM, err := readPEMValue(certValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read healthCheckClientCert: %v", err)
+	}
+	keyPEM, err := readPEMValue(keyValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read healthCheckClientKey: %v", err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid health check client certificate: %v", err)
+	}
+	return &cert, nil
+}
+
+// readPEMValue returns value itself when it is inline PEM, otherwise the contents of the file it names
+func readPEMValue(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value)
+}
+
+// Health check modes for combining multiple healthChecks URLs
+const (
+	healthCheckModeAll = "all"
+	healthCheckModeAny = "any"
+)
+
+// Health check types selecting how performHealthCheck decides whether the service is up
+const (
+	healthCheckTypeHTTP = "http"
+	healthCheckTypeGRPC = "grpc"
+	healthCheckTypeARP  = "arp"
+)
+
+// defaultARPTablePath is the Linux kernel's view of the neighbour table
+const defaultARPTablePath = "/proc/net/arp"
+
+// performHealthCheck probes every health check URL concurrently and combines the results per healthCheckMode
+func (w *WOLPlugin) performHealthCheck() bool {
+	return w.performHealthCheckWithHeaders(nil)
+}
+
+// performHealthCheckWithHeaders is performHealthCheck with extra headers added to HTTP probes
+func (w *WOLPlugin) performHealthCheckWithHeaders(forwarded http.Header) bool {
+	if w.healthCheckType == healthCheckTypeARP {
+		return w.checkARP()
+	}
+	probe := func(healthURL string) bool {
+		return w.checkHealthURLWithHeaders(healthURL, forwarded)
+	}
+	if w.healthCheckType == healthCheckTypeGRPC {
+		probe = w.checkGRPCHealth
+	}
+	check := func(healthURL string) bool {
+		return w.timeHealthProbe(healthURL, probe)
+	}
+	if len(w.healthChecks) == 1 {
+		return check(w.healthChecks[0])
+	}
+
+	results := make([]bool, len(w.healthChecks))
+	var wg sync.WaitGroup
+	for i, healthURL := range w.healthChecks {
+		wg.Add(1)
+		go func(i int, healthURL string) {
+			defer wg.Done()
+			results[i] = check(healthURL)
+		}(i, healthURL)
+	}
+	wg.Wait()
+
+	for _, healthy := range results {
+		if healthy && w.healthCheckMode == healthCheckModeAny {
+			return true
+		}
+		if !healthy && w.healthCheckMode == healthCheckModeAll {
+			return false
+		}
+	}
+	return w.healthCheckMode == healthCheckModeAll
+}
+
+// timeHealthProbe runs probe against healthURL and records how long it took. With healthCheckMaxLatency set, a
+// probe slower than that counts as unhealthy whatever it returned. Wall-clock time is used because request
+// durations are real even when w.now is faked.
+func (w *WOLPlugin) timeHealthProbe(healthURL string, probe func(string) bool) bool {
+	start := time.Now()
+	healthy := probe(healthURL)
+	latency := time.Since(start)
+
+	w.healthLatencyMutex.Lock()
+	w.healthLatency = latency
+	w.healthLatencyMutex.Unlock()
+
+	if healthy && w.healthCheckMaxLatency > 0 && latency > w.healthCheckMaxLatency {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Health check for %s took %v, over healthCheckMaxLatency %v\n", w.name, healthURL, latency, w.healthCheckMaxLatency)
+		}
+		return false
+	}
+	return healthy
+}
+
+// lastHealthLatency returns how long the most recent HTTP or gRPC health probe took
+func (w *WOLPlugin) lastHealthLatency() time.Duration {
+	w.healthLatencyMutex.Lock()
+	defer w.healthLatencyMutex.Unlock()
+	return w.healthLatency
+}
+
+// checkARP reports whether the target has a completed entry in the system ARP table. This only shows the
+// network interface is up, which usually happens well before the services on the machine are ready.
+func (w *WOLPlugin) checkARP() bool {
+	table, err := os.ReadFile(w.arpTablePath)
+	if err != nil {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Failed to read ARP table: %v\n", w.name, err)
+		}
+		return false
+	}
+
+	macBytes, err := w.parseMACAddress(w.macAddress)
+	if err != nil {
+		return false
+	}
+
+	present := arpTableContains(string(table), macBytes, w.ipAddress)
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: ARP check for %s: present=%v\n", w.name, w.macAddress, present)
+	}
+	return present
+}
+
+// arpTableContains reports whether a /proc/net/arp style table has a completed entry for mac,
+// additionally requiring the entry's IP to match ip when one is given
+func arpTableContains(table string, mac []byte, ip string) bool {
+	lines := strings.Split(table, "\n")
+	for _, line := range lines[1:] { // skip the header row
+		// IP address, HW type, Flags, HW address, Mask, Device
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if err != nil || flags&0x2 == 0 { // ATF_COM: the entry is resolved
+			continue
+		}
+		if ip != "" && fields[0] != ip {
+			continue
+		}
+
+		hwAddr, err := net.ParseMAC(fields[3])
+		if err == nil && bytes.Equal(hwAddr, mac) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classes of failed health probe connections, from classifyDialError
+const (
+	dialRefused     = "refused"     // the host answered, but nothing is listening on the port yet
+	dialUnreachable = "unreachable" // the host did not answer, for example because it is still booting
+)
+
+// classifyDialError tells a refused connection, showing the host is up, from one that timed out or found no
+// route to the host. Other errors and nil give "". Errors are matched on their text because the syscall
+// package is not available to the plugin interpreter.
+func classifyDialError(err error) string {
+	if err == nil {
+		return ""
+	}
+	message := err.Error()
+	if strings.Contains(message, "connection refused") {
+		return dialRefused
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return dialUnreachable
+	}
+	for _, unreachable := range []string{"no route to host", "host is down", "network is unreachable"} {
+		if strings.Contains(message, unreachable) {
+			return dialUnreachable
+		}
+	}
+	return ""
+}
+
+// setDialFailure records how the most recent health probe failed to connect, or "" when it connected
+func (w *WOLPlugin) setDialFailure(class string) {
+	w.dialFailureMutex.Lock()
+	w.dialFailure = class
+	w.dialFailureMutex.Unlock()
+}
+
+// lastDialFailure returns the dialRefused or dialUnreachable class of the most recent health probe, or ""
+// when it connected or failed some other way
+func (w *WOLPlugin) lastDialFailure() string {
+	w.dialFailureMutex.Lock()
+	defer w.dialFailureMutex.Unlock()
+	return w.dialFailure
+}
+
+// checkHealthURL performs a single health check request against healthURL
+func (w *WOLPlugin) checkHealthURL(healthURL string) bool {
+	return w.checkHealthURLWithHeaders(healthURL, nil)
+}
+
+// healthCheckHeaderConfigured reports whether healthCheckHeaders sets the named header
+func (w *WOLPlugin) healthCheckHeaderConfigured(name string) bool {
+	for configured := range w.healthCheckHeaders {
+		if strings.EqualFold(configured, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHealthURLWithHeaders is checkHealthURL with forwarded request headers on the probe. Headers set by
+// healthCheckHeaders are not overridden.
+func (w *WOLPlugin) checkHealthURLWithHeaders(healthURL string, forwarded http.Header) bool {
+	// Create request with proper headers
+	var body io.Reader
+	if w.healthCheckBody != "" {
+		body = strings.NewReader(w.healthCheckBody)
+	}
+	req, err := http.NewRequest(w.healthCheckMethod, healthURL, body)
+	if err != nil {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Health check request creation failed: %v\n", w.name, err)
+		}
+		return false
+	}
+	
+	// Add headers to avoid caching and identify the health checker
+	req.Header.Set("User-Agent", w.healthCheckUserAgent)
+	req.Header.Set("Cache-Control", "no-cache")
+	if !w.healthCheckDisableKeepAlive {
+		req.Header.Set("Connection", "keep-alive")
+	}
+	if w.healthCheckBody != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// Configured headers take precedence over the defaults above
+	for name, value := range w.healthCheckHeaders {
+		req.Header.Set(name, value)
+	}
+	for name, values := range forwarded {
+		if !w.healthCheckHeaderConfigured(name) {
+			req.Header[name] = values
+		}
+	}
+
+	// A probe that never got a connection tells how far the host is from serving
+	var connMutex sync.Mutex
+	connected := false
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			connMutex.Lock()
+			connected = true
+			connMutex.Unlock()
+		},
+	}))
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		connMutex.Lock()
+		dialed := connected
+		connMutex.Unlock()
+		if dialed {
+			w.setDialFailure("")
+		} else {
+			w.setDialFailure(classifyDialError(err))
+		}
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Health check failed: %v\n", w.name, err)
+		}
+		return false
+	}
+	w.setDialFailure("")
+	defer func() {
+		// Ensure body is read and closed for connection reuse
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+	}()
+
+	healthy := resp.StatusCode >= 200 && resp.StatusCode < 300
+	if healthy && (w.healthCheckExpectBody != "" || w.healthCheckExpectBodyRegex != nil) {
+		healthy = w.healthBodyMatches(resp.Body)
+	}
+	
+	// Log health status changes more intelligently
+	if w.debug && !(healthy && w.quietWhenHealthy) {
+		fmt.Printf("WOL Plugin [%s]: Health check status: %d (healthy: %v) for %s\n", 
+			w.name, resp.StatusCode, healthy, healthURL)
+	}
+	
+	return healthy
+}
+
+// defaultMaxHealthBodyBytes bounds how much of a health check response is read for body matching
+const defaultMaxHealthBodyBytes = 64 << 10
+
+// healthBodyMatches reads up to maxHealthBodyBytes of a health check response and reports whether it contains
+// healthCheckExpectBody and matches healthCheckExpectBodyRegex, each only checked when configured
+func (w *WOLPlugin) healthBodyMatches(body io.Reader) bool {
+	limit := w.maxHealthBodyBytes
+	if limit <= 0 {
+		limit = defaultMaxHealthBodyBytes
+	}
+	content, err := io.ReadAll(io.LimitReader(body, limit))
+	if err != nil {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Failed to read health check body: %v\n", w.name, err)
+		}
+		return false
+	}
+
+	if w.healthCheckExpectBody != "" && !bytes.Contains(content, []byte(w.healthCheckExpectBody)) {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Health check body does not contain %q\n", w.name, w.healthCheckExpectBody)
+		}
+		return false
+	}
+	if w.healthCheckExpectBodyRegex != nil && !w.healthCheckExpectBodyRegex.Match(content) {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Health check body does not match %q\n", w.name, w.healthCheckExpectBodyRegex)
+		}
+		return false
+	}
+	return true
+}
+
+// The gRPC Health Checking Protocol runs over HTTP/2, which net/http only speaks over TLS and cannot offer for
+// cleartext targets without golang.org/x/net, which Yaegi cannot load. checkGRPCHealth therefore drives the single
+// grpc.health.v1.Health/Check call itself with just enough HTTP/2 framing.
+
+// grpcHealthCheckPath is the method path of the standard health check RPC
+const grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+
+// grpcHealthServing is the HealthCheckResponse.ServingStatus value for SERVING
+const grpcHealthServing = 1
+
+// HTTP/2 frame types and flags used by the gRPC health client
+const (
+	h2FrameData       = 0x0
+	h2FrameHeaders    = 0x1
+	h2FrameRSTStream  = 0x3
+	h2FrameSettings   = 0x4
+	h2FramePing       = 0x6
+	h2FrameGoAway     = 0x7
+	h2FlagEndStream   = 0x1
+	h2FlagAck         = 0x1
+	h2FlagEndHeaders  = 0x4
+	h2FlagPadded      = 0x8
+	h2ClientPreface   = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+	h2MaxFramePayload = 1 << 20
+	grpcHealthTimeout = 10 * time.Second
+)
+
+// parseGRPCHealthTarget accepts "host:port", "grpc://host:port" or "grpcs://host:port" for TLS
+func parseGRPCHealthTarget(target string) (string, bool, error) {
+	address := target
+	useTLS := false
+	if i := strings.Index(target, "://"); i >= 0 {
+		switch strings.ToLower(target[:i]) {
+		case "grpc":
+		case "grpcs":
+			useTLS = true
+		default:
+			return "", false, fmt.Errorf("invalid healthCheck target %q: scheme must be grpc or grpcs", target)
+		}
+		address = strings.TrimSuffix(target[i+3:], "/")
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid healthCheck target %q: expected host:port", target)
+	}
+	if host == "" {
+		return "", false, fmt.Errorf("invalid healthCheck target %q: missing host", target)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", false, fmt.Errorf("invalid healthCheck target %q: invalid port", target)
+	}
+	return address, useTLS, nil
+}
+
+// checkGRPCHealth calls grpc.health.v1.Health/Check on target and reports whether it answered SERVING
+func (w *WOLPlugin) checkGRPCHealth(target string) bool {
+	status, err := w.grpcHealthStatus(target)
+	if err != nil {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: gRPC health check failed for %s: %v\n", w.name, target, err)
+		}
+		return false
+	}
+
+	healthy := status == grpcHealthServing
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: gRPC health check status: %d (healthy: %v) for %s\n", w.name, status, healthy, target)
+	}
+	return healthy
+}
+
+// grpcHealthStatus performs one Check RPC over a fresh connection and returns the ServingStatus
+func (w *WOLPlugin) grpcHealthStatus(target string) (int, error) {
+	address, useTLS, err := parseGRPCHealthTarget(target)
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := net.DialTimeout("tcp", address, grpcHealthTimeout)
+	w.setDialFailure(classifyDialError(err))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(grpcHealthTimeout))
+
+	scheme := "http"
+	if useTLS {
+		config := &tls.Config{NextProtos: []string{"h2"}}
+		if w.grpcTLSConfig != nil {
+			config = w.grpcTLSConfig.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			return 0, fmt.Errorf("TLS handshake failed: %v", err)
+		}
+		if proto := tlsConn.ConnectionState().NegotiatedProtocol; proto != "h2" {
+			return 0, fmt.Errorf("server did not negotiate HTTP/2")
+		}
+		conn = tlsConn
+		scheme = "https"
+	}
+
+	var headers []byte
+	headers = append(headers, 0x83) // :method POST
+	if scheme == "https" {
+		headers = append(headers, 0x87) // :scheme https
+	} else {
+		headers = append(headers, 0x86) // :scheme http
+	}
+	headers = hpackAppendIndexedName(headers, 4, grpcHealthCheckPath) // :path
+	headers = hpackAppendIndexedName(headers, 1, address)             // :authority
+	headers = hpackAppendLiteral(headers, "content-type", "application/grpc")
+	headers = hpackAppendLiteral(headers, "te", "trailers")
+	headers = hpackAppendLiteral(headers, "user-agent", w.healthCheckUserAgent)
+
+	// HealthCheckRequest{service} as a length-prefixed, uncompressed gRPC message
+	var request []byte
+	if w.healthCheckGRPCService != "" {
+		request = append(request, 0x0A)
+		request = binary.AppendUvarint(request, uint64(len(w.healthCheckGRPCService)))
+		request = append(request, w.healthCheckGRPCService...)
+	}
+	message := []byte{0}
+	message = binary.BigEndian.AppendUint32(message, uint32(len(request)))
+	message = append(message, request...)
+
+	out := []byte(h2ClientPreface)
+	out = h2AppendFrame(out, h2FrameSettings, 0, 0, nil)
+	out = h2AppendFrame(out, h2FrameHeaders, h2FlagEndHeaders, 1, headers)
+	out = h2AppendFrame(out, h2FrameData, h2FlagEndStream, 1, message)
+	if _, err := conn.Write(out); err != nil {
+		return 0, err
+	}
+
+	// Response headers and trailers are HPACK-compressed and not needed: a successful call carries exactly one
+	// response message, and a failed one ends in trailers without any
+	reader := bufio.NewReader(conn)
+	var data []byte
+	for {
+		var header [9]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return 0, err
+		}
+		length := int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+		frameType, flags := header[3], header[4]
+		streamID := binary.BigEndian.Uint32(header[5:]) & 0x7FFFFFFF
+		if length > h2MaxFramePayload {
+			return 0, fmt.Errorf("HTTP/2 frame too large")
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return 0, err
+		}
+
+		switch frameType {
+		case h2FrameSettings:
+			if flags&h2FlagAck == 0 {
+				if _, err := conn.Write(h2AppendFrame(nil, h2FrameSettings, h2FlagAck, 0, nil)); err != nil {
+					return 0, err
+				}
+			}
+		case h2FramePing:
+			if flags&h2FlagAck == 0 {
+				if _, err := conn.Write(h2AppendFrame(nil, h2FramePing, h2FlagAck, 0, payload)); err != nil {
+					return 0, err
+				}
+			}
+		case h2FrameGoAway:
+			return 0, fmt.Errorf("server closed the connection")
+		case h2FrameRSTStream:
+			if streamID == 1 {
+				return 0, fmt.Errorf("server reset the stream")
+			}
+		case h2FrameData, h2FrameHeaders:
+			if streamID != 1 {
+				continue
+			}
+			if frameType == h2FrameData {
+				if flags&h2FlagPadded != 0 && len(payload) > 0 {
+					padding := int(payload[0])
+					if padding >= len(payload) {
+						return 0, fmt.Errorf("invalid HTTP/2 padding")
+					}
+					payload = payload[1 : len(payload)-padding]
+				}
+				data = append(data, payload...)
+			}
+			if flags&h2FlagEndStream != 0 {
+				return parseGRPCHealthResponse(data)
+			}
+		}
+	}
+}
+
+// parseGRPCHealthResponse extracts ServingStatus from a length-prefixed HealthCheckResponse message
+func parseGRPCHealthResponse(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, fmt.Errorf("call failed without a response message")
+	}
+	if data[0] != 0 {
+		return 0, fmt.Errorf("compressed responses are not supported")
+	}
+	length := binary.BigEndian.Uint32(data[1:5])
+	if uint32(len(data)-5) < length {
+		return 0, fmt.Errorf("truncated response message")
+	}
+	message := data[5 : 5+length]
+
+	// An absent status field means its zero value, UNKNOWN
+	status := 0
+	for len(message) > 0 {
+		tag, n := binary.Uvarint(message)
+		if n <= 0 {
+			return 0, fmt.Errorf("malformed response message")
+		}
+		message = message[n:]
+		switch tag & 7 {
+		case 0:
+			value, n := binary.Uvarint(message)
+			if n <= 0 {
+				return 0, fmt.Errorf("malformed response message")
+			}
+			message = message[n:]
+			if tag>>3 == 1 {
+				status = int(value)
+			}
+		case 2:
+			size, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < size {
+				return 0, fmt.Errorf("malformed response message")
+			}
+			message = message[n+int(size):]
+		default:
+			return 0, fmt.Errorf("malformed response message")
+		}
+	}
+	return status, nil
+}
+
+// h2AppendFrame appends an HTTP/2 frame with the given header fields and payload
+func h2AppendFrame(b []byte, frameType, flags byte, streamID uint32, payload []byte) []byte {
+	b = append(b, byte(len(payload)>>16), byte(len(payload)>>8), byte(len(payload)), frameType, flags)
+	b = binary.BigEndian.AppendUint32(b, streamID)
+	return append(b, payload...)
+}
+
+// hpackAppendInteger appends an HPACK integer with an n-bit prefix, OR-ing the first byte with flags
+func hpackAppendInteger(b []byte, flags byte, n uint, value int) []byte {
+	max := 1<<n - 1
+	if value < max {
+		return append(b, flags|byte(value))
+	}
+	b = append(b, flags|byte(max))
+	value -= max
+	for value >= 128 {
+		b = append(b, byte(value%128+128))
+		value /= 128
+	}
+	return append(b, byte(value))
+}
+
+// hpackAppendString appends a string literal without Huffman coding
+func hpackAppendString(b []byte, value string) []byte {
+	b = hpackAppendInteger(b, 0, 7, len(value))
+	return append(b, value...)
+}
+
+// hpackAppendIndexedName appends a literal header field without indexing, naming a static table entry
+func hpackAppendIndexedName(b []byte, index int, value string) []byte {
+	b = hpackAppendInteger(b, 0, 4, index)
+	return hpackAppendString(b, value)
+}
+
+// hpackAppendLiteral appends a literal header field without indexing with a literal name
+func hpackAppendLiteral(b []byte, name, value string) []byte {
+	b = append(b, 0)
+	b = hpackAppendString(b, name)
+	return hpackAppendString(b, value)
+}
+
+
+// interfaceProvider lists the host's network interfaces and their addresses. Tests replace it to feed
+// synthetic interfaces to broadcast discovery.
+type interfaceProvider interface {
+	Interfaces() ([]net.Interface, error)
+	Addrs(iface net.Interface) ([]net.Addr, error)
+}
+
+// netInterfaceProvider is the default interfaceProvider, backed by the net package
+type netInterfaceProvider struct{}
+
+func (netInterfaceProvider) Interfaces() ([]net.Interface, error) {
+	return net.Interfaces()
+}
+
+func (netInterfaceProvider) Addrs(iface net.Interface) ([]net.Addr, error) {
+	return iface.Addrs()
+}
+
+// interfaceByName looks up a network interface through the interface provider
+func (w *WOLPlugin) interfaceByName(name string) (net.Interface, error) {
+	interfaces, err := w.interfaces.Interfaces()
+	if err != nil {
+		return net.Interface{}, err
+	}
+	for _, iface := range interfaces {
+		if iface.Name == name {
+			return iface, nil
+		}
+	}
+	return net.Interface{}, fmt.Errorf("no such network interface")
+}
+
+// getNetworkInterfaces returns available network interfaces for WOL packet sending
+func (w *WOLPlugin) getNetworkInterfaces() ([]net.Interface, error) {
+	interfaces, err := w.interfaces.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interfaces: %v", err)
+	}
+
+	var validInterfaces []net.Interface
+	for _, iface := range interfaces {
+		// Skip loopback and down interfaces
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		
+		// If specific interface is configured, only use that one
+		if w.networkInterface != "" && iface.Name != w.networkInterface {
+			continue
+		}
+		
+		validInterfaces = append(validInterfaces, iface)
+	}
+	
+	if len(validInterfaces) == 0 {
+		return nil, fmt.Errorf("no valid network interfaces found")
+	}
+	
+	return validInterfaces, nil
+}
+
+// calculateBroadcastAddress calculates broadcast address for a given network
+func (w *WOLPlugin) calculateBroadcastAddress(ip net.IP, mask net.IPMask) net.IP {
+	if ip == nil || mask == nil {
+		return nil
+	}
+	
+	network := ip.Mask(mask)
+	broadcast := make(net.IP, len(network))
+	for i := range network {
+		broadcast[i] = network[i] | ^mask[i]
+	}
+	
+	return broadcast
+}
+
+// limitedBroadcastAddress is sent to when auto-discovery finds no broadcast address of its own
+const limitedBroadcastAddress = "255.255.255.255"
+
+// getBroadcastAddresses returns all possible broadcast addresses for WOL
+func (w *WOLPlugin) getBroadcastAddresses() []string {
+	addresses, _ := w.discoverBroadcastAddresses()
+	return addresses
+}
+
+// discoverBroadcastAddresses returns the broadcast addresses for WOL along with, when it had to fall back
+// to the limited broadcast, the reason why
+func (w *WOLPlugin) discoverBroadcastAddresses() ([]string, string) {
+	var addresses []string
+	
+	// Use configured broadcast addresses if provided
+	if len(w.broadcastAddresses) > 0 {
+		return append(addresses, w.broadcastAddresses...), ""
+	}
+	
+	// Auto-discover broadcast addresses
+	interfaces, err := w.getNetworkInterfaces()
+	if err != nil {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Failed to get interfaces: %v\n", w.name, err)
+		}
+		if len(w.allowedSubnets) > 0 {
+			return addresses, ""
+		}
+		return append(addresses, limitedBroadcastAddress), err.Error()
+	}
+	
+	for _, iface := range interfaces {
+		// IPv6 has no broadcast; the all-nodes link-local multicast group reaches the same segment
+		if w.enableIPv6 && iface.Flags&net.FlagMulticast != 0 {
+			addresses = append(addresses, ipv6AllNodesMulticast+"%"+iface.Name)
+		}
+
+		addrs, err := w.interfaces.Addrs(iface)
+		if err != nil {
+			continue
+		}
+		
+		addresses = append(addresses, w.broadcastAddressesFor(addrs)...)
+	}
+	
+	// Add common broadcast addresses as fallback, unless subnet filtering ruled everything out
+	if len(addresses) == 0 && len(w.allowedSubnets) == 0 {
+		return append(addresses, limitedBroadcastAddress), "no network interface has an IPv4 broadcast address"
+	}
+	
+	return addresses, ""
+}
+
+// Tokens accepted in wakeTargetOrder besides specific broadcast IPs
+const (
+	wakeTargetUnicast      = "unicast"
+	wakeTargetAllBroadcast = "all-broadcast"
+)
+
+// wakeTarget is one address a magic packet is sent to
+type wakeTarget struct {
+	kind     string // "unicast" or "broadcast", for logging
+	address  string
+	fallback string // why auto-discovery fell back to this limited broadcast target, if it did
+}
+
+// parseBroadcastAddresses merges the comma-separated broadcastAddress with the broadcastAddresses list,
+// dropping duplicates and empty entries
+func parseBroadcastAddresses(single string, list []string) ([]string, error) {
+	var addresses []string
+	seen := make(map[string]bool)
+	for _, entry := range append(strings.Split(single, ","), list...) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || seen[entry] {
+			continue
+		}
+		if err := validateTargetAddress(entry); err != nil {
+			return nil, fmt.Errorf("invalid broadcastAddress %q: %v", entry, err)
+		}
+		seen[entry] = true
+		addresses = append(addresses, entry)
+	}
+	return addresses, nil
+}
+
+// validateTargetAddress checks a wake target is an IP address, accepting a zone suffix so IPv6 multicast
+// targets like "ff02::1%eth0" can be listed, and an optional port such as "192.168.1.255:7"
+func validateTargetAddress(target string) error {
+	host, _, err := splitTargetPort(target)
+	if err != nil {
+		return err
+	}
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	if net.ParseIP(host) == nil {
+		return fmt.Errorf("%q is not an IP address", host)
+	}
+	return nil
+}
+
+// parseWakeTargetOrder validates the wakeTargetOrder tokens, defaulting to unicast then every broadcast address
+func parseWakeTargetOrder(order []string, ipAddress string) ([]string, error) {
+	if len(order) == 0 {
+		return []string{wakeTargetUnicast, wakeTargetAllBroadcast}, nil
+	}
+
+	seen := make(map[string]bool)
+	tokens := make([]string, 0, len(order))
+	for _, entry := range order {
+		token := strings.ToLower(strings.TrimSpace(entry))
+		switch token {
+		case wakeTargetUnicast:
+			if ipAddress == "" {
+				return nil, fmt.Errorf("wakeTargetOrder includes %q but ipAddress is not set", wakeTargetUnicast)
+			}
+		case wakeTargetAllBroadcast:
+		default:
+			if err := validateTargetAddress(token); err != nil {
+				return nil, fmt.Errorf("invalid wakeTargetOrder entry %q: must be %q, %q or a broadcast IP: %v", entry, wakeTargetUnicast, wakeTargetAllBroadcast, err)
+			}
+		}
+		if seen[token] {
+			return nil, fmt.Errorf("duplicate wakeTargetOrder entry %q", entry)
+		}
+		seen[token] = true
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// wakeTargets expands wakeTargetOrder into the addresses to send to, in order. "all-broadcast" adds
+// every discovered broadcast address not already listed explicitly.
+func (w *WOLPlugin) wakeTargets() []wakeTarget {
+	order := w.wakeTargetOrder
+	if len(order) == 0 {
+		order = []string{wakeTargetUnicast, wakeTargetAllBroadcast}
+	}
+
+	listed := make(map[string]bool)
+	for _, token := range order {
+		listed[token] = true
+	}
+
+	var targets []wakeTarget
+	for _, token := range order {
+		switch token {
+		case wakeTargetUnicast:
+			if w.ipAddress != "" {
+				targets = append(targets, wakeTarget{kind: "unicast", address: w.ipAddress})
+			}
+		case wakeTargetAllBroadcast:
+			addresses, fallback := w.discoverBroadcastAddresses()
+			for _, address := range addresses {
+				if !listed[address] {
+					targets = append(targets, wakeTarget{kind: "broadcast", address: address, fallback: fallback})
+				}
+			}
+		default:
+			targets = append(targets, wakeTarget{kind: "broadcast", address: token})
+		}
+	}
+	return targets
+}
+
+// broadcastAddressesFor computes the IPv4 broadcast addresses of an interface's addresses,
+// keeping only networks inside allowedSubnets when it is configured
+func (w *WOLPlugin) broadcastAddressesFor(addrs []net.Addr) []string {
+	var addresses []string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		if !w.subnetAllowed(ipNet) {
+			if w.debug {
+				fmt.Printf("WOL Plugin [%s]: Skipping broadcast for %s, not in allowedSubnets\n", w.name, ipNet)
+			}
+			continue
+		}
+		broadcast := w.calculateBroadcastAddress(ipNet.IP, ipNet.Mask)
+		if broadcast != nil {
+			addresses = append(addresses, broadcast.String())
+		}
+	}
+	return addresses
+}
+
+// subnetAllowed reports whether network lies entirely within one of the allowed subnets
+func (w *WOLPlugin) subnetAllowed(network *net.IPNet) bool {
+	if len(w.allowedSubnets) == 0 {
+		return true
+	}
+	ones, bits := network.Mask.Size()
+	for _, allowed := range w.allowedSubnets {
+		allowedOnes, allowedBits := allowed.Mask.Size()
+		if allowed.Contains(network.IP.Mask(network.Mask)) && bits == allowedBits && ones >= allowedOnes {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIPAllowlist parses a list of IPs and CIDRs, treating a bare IP as a single-host network
+func parseIPAllowlist(field string, entries []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: expected an IP or CIDR", field, entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// clientIP returns the address of the client, using the last X-Forwarded-For hop when trustForwardedFor is set.
+// The last hop is the one added by the proxy directly in front of Traefik, so earlier entries can't spoof it.
+func (w *WOLPlugin) clientIP(req *http.Request) net.IP {
+	if w.trustForwardedFor {
+		if forwarded := req.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := net.ParseIP(strings.TrimSpace(hops[len(hops)-1])); ip != nil {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// ipInNetworks reports whether ip lies in any of the networks
+func ipInNetworks(ip net.IP, networks []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// controlIPAllowed reports whether the client may use the control endpoints; any client is allowed when unset
+func (w *WOLPlugin) controlIPAllowed(req *http.Request) bool {
+	if len(w.allowedControlIPs) == 0 {
+		return true
+	}
+	return ipInNetworks(w.clientIP(req), w.allowedControlIPs)
+}
+
+// isReadOnlyClient reports whether the client is in readOnlyControlIPs or sends one of readOnlyRoles in
+// controlRoleHeader. Such clients see the control page without the wake and power-off buttons, and their
+// wake, power-off and cancel requests are refused.
+func (w *WOLPlugin) isReadOnlyClient(req *http.Request) bool {
+	if len(w.readOnlyControlIPs) > 0 && ipInNetworks(w.clientIP(req), w.readOnlyControlIPs) {
+		return true
+	}
+	if w.controlRoleHeader == "" {
+		return false
+	}
+	for _, value := range req.Header.Values(w.controlRoleHeader) {
+		for _, role := range strings.Split(value, ",") {
+			role = strings.TrimSpace(role)
+			for _, readOnly := range w.readOnlyRoles {
+				if strings.EqualFold(role, readOnly) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// parseAllowedSubnets parses the allowedSubnets CIDR list
+func parseAllowedSubnets(entries []string) ([]*net.IPNet, error) {
+	var subnets []*net.IPNet
+	for _, entry := range entries {
+		_, subnet, err := net.ParseCIDR(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowedSubnets entry %q: %v", entry, err)
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
+
+func (w *WOLPlugin) sendWOLPacket() error {
+	macBytes, err := w.parseMACAddress(w.macAddress)
+	if err != nil {
+		return fmt.Errorf("invalid MAC address: %v", err)
+	}
+
+	packet := w.createMagicPacket(macBytes)
+	if w.dryRun {
+		w.logDryRunWake(packet)
+		return nil
+	}
+
+	switch w.wakeMethod {
+	case wakeMethodGateway:
+		return w.sendGatewayWake(macBytes)
+	case wakeMethodBoth:
+		// Either path reaching the host is enough; the other's failure is only logged
+		localErr := w.sendLocalWake(packet)
+		gatewayErr := w.sendGatewayWake(macBytes)
+		if localErr != nil && gatewayErr != nil {
+			return fmt.Errorf("%v; %v", localErr, gatewayErr)
+		}
+		if localErr != nil {
+			fmt.Printf("WOL Plugin [%s]: Local wake failed, gateway wake succeeded: %v\n", w.name, localErr)
+		}
+		if gatewayErr != nil {
+			fmt.Printf("WOL Plugin [%s]: Gateway wake failed, local wake succeeded: %v\n", w.name, gatewayErr)
+		}
+		return nil
+	}
+	return w.sendLocalWake(packet)
+}
+
+// sendLocalWake sends the magic packet from this host over the configured wakeTransport
+func (w *WOLPlugin) sendLocalWake(packet []byte) error {
+	if w.wakeTransport == wakeTransportEthernet {
+		err := w.sendEthernetWake(packet)
+		if !errors.Is(err, errRawFramesUnsupported) {
+			return err
+		}
+		w.ethernetFallback.Do(func() {
+			fmt.Printf("WOL Plugin [%s]: %v; falling back to UDP\n", w.name, err)
+		})
+	}
+
+	var sentSuccessfully bool
+	var lastError error
+
+	// By default unicast to the specific IP goes first, then every broadcast address for better
+	// container/LXC compatibility
+	targets := w.wakeTargets()
+	fallback := ""
+	for _, target := range targets {
+		if target.fallback != "" {
+			fallback = target.fallback
+			// Restricted containers often expose no usable interface, which would otherwise go unnoticed
+			fmt.Printf("WOL Plugin [%s]: Broadcast discovery failed (%s); falling back to limited broadcast %s\n",
+				w.name, fallback, w.targetEndpoint(target.address))
+			break
+		}
+	}
+	w.wakeMutex.Lock()
+	w.wakeCache.broadcastFallback = fallback
+	w.wakeMutex.Unlock()
+
+	if w.parallelWakeSends {
+		sentSuccessfully, lastError = w.sendParallel(packet, targets)
+	} else {
+		for _, target := range targets {
+			err := w.sendRepeated(packet, target.address)
+			w.logSendResult(target, err)
+			if err == nil {
+				sentSuccessfully = true
+				if w.stopOnFirstSuccess {
+					break
+				}
+			} else {
+				lastError = err
+			}
+		}
+	}
+
+	if !sentSuccessfully {
+		if fallback != "" {
+			return fmt.Errorf("failed to send WOL packet to any address (%s, tried limited broadcast %s): %v", fallback, limitedBroadcastAddress, lastError)
+		}
+		return fmt.Errorf("failed to send WOL packet to any address: %v", lastError)
+	}
+
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: Magic packet sent to %s\n", w.name, w.macAddress)
+	}
+	return nil
+}
+
+// sendParallel sends the magic packet to every target at once, so a target that is slow to dial does not
+// hold up the others. It succeeds if any send succeeds, returning an error from one that failed otherwise.
+func (w *WOLPlugin) sendParallel(packet []byte, targets []wakeTarget) (bool, error) {
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target wakeTarget) {
+			defer wg.Done()
+			errs[i] = w.sendRepeated(packet, target.address)
+			w.logSendResult(target, errs[i])
+		}(i, target)
+	}
+	wg.Wait()
+
+	sent := false
+	var lastError error
+	for _, err := range errs {
+		if err == nil {
+			sent = true
+		} else {
+			lastError = err
+		}
+	}
+	return sent, lastError
+}
+
+// logSendResult logs, in debug mode, how sending the magic packet to one target went
+func (w *WOLPlugin) logSendResult(target wakeTarget, err error) {
+	if !w.debug {
+		return
+	}
+	if err != nil {
+		fmt.Printf("WOL Plugin [%s]: %s to %s failed: %v\n", w.name, target.kind, target.address, err)
+		return
+	}
+	fmt.Printf("WOL Plugin [%s]: Magic packet sent via %s to %s (%s)\n", w.name, target.kind, w.macAddress, w.targetEndpoint(target.address))
+}
+
+// Transports for the magic packet
+const (
+	wakeTransportUDP      = "udp"
+	wakeTransportEthernet = "ethernet"
+)
+
+// Ways of waking the host: sending the magic packet locally, asking a remote WOL gateway, or both
+const (
+	wakeMethodLocal   = "local"
+	wakeMethodGateway = "gateway"
+	wakeMethodBoth    = "both"
+)
+
+const (
+	// defaultGatewayBody is posted to gatewayURL when gatewayBody is unset
+	defaultGatewayBody = `{"mac":"{mac}"}`
+	// defaultGatewayTimeout bounds each call to gatewayURL
+	defaultGatewayTimeout = 10 * time.Second
+)
+
+// gatewayRequestBody fills the {mac} and {ip} placeholders of gatewayBody
+func (w *WOLPlugin) gatewayRequestBody(macBytes []byte) string {
+	return strings.NewReplacer("{mac}", net.HardwareAddr(macBytes).String(), "{ip}", w.ipAddress).Replace(w.gatewayBody)
+}
+
+// sendGatewayWake asks the WOL gateway at gatewayURL to wake the host, bounded by gatewayTimeout. Any 2xx
+// response counts as success.
+func (w *WOLPlugin) sendGatewayWake(macBytes []byte) error {
+	ctx, cancel := context.WithTimeout(w.ctx, w.gatewayTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.gatewayURL, strings.NewReader(w.gatewayRequestBody(macBytes)))
+	if err != nil {
+		return fmt.Errorf("gateway wake failed: %v", err)
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	req.Header.Set("Content-Type", "application/json")
+	if w.gatewayToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.gatewayToken)
+	}
+	for name, value := range w.gatewayHeaders {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.gatewayClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("gateway wake failed: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("gateway wake failed: gateway returned status %d", resp.StatusCode)
+	}
+
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: Wake request for %s accepted by gateway %s (status %d)\n", w.name, w.macAddress, redactURL(w.gatewayURL), resp.StatusCode)
+	}
+	return nil
+}
+
+// etherTypeWakeOnLAN is the EtherType of a Wake-on-LAN frame
+const etherTypeWakeOnLAN = 0x0842
+
+// errRawFramesUnsupported is returned by sendRawFrame where raw layer-2 sockets aren't available
+var errRawFramesUnsupported = errors.New("raw Ethernet frames need AF_PACKET sockets, which the plugin interpreter does not provide")
+
+// sendRawFrame is the default frame sender. Opening an AF_PACKET socket needs the syscall package, which
+// Traefik's plugin interpreter does not expose, so it always reports errRawFramesUnsupported and the
+// caller falls back to UDP until code embedding the plugin registers a real sender with SetFrameSender.
+func sendRawFrame(ifaceName string, frame []byte) error {
+	return fmt.Errorf("cannot send on %s: %w", ifaceName, errRawFramesUnsupported)
+}
+
+// sendEthernetWake broadcasts the magic packet as an EtherType 0x0842 frame out of networkInterface
+func (w *WOLPlugin) sendEthernetWake(packet []byte) error {
+	iface, err := w.interfaceByName(w.networkInterface)
+	if err != nil {
+		return fmt.Errorf("interface %s not found: %v", w.networkInterface, err)
+	}
+	if len(iface.HardwareAddr) != 6 {
+		return fmt.Errorf("interface %s has no Ethernet address", w.networkInterface)
+	}
+
+	frame := buildEthernetFrame(net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, iface.HardwareAddr, packet)
+	w.sendFrameMutex.RLock()
+	sendFrame := w.sendFrame
+	w.sendFrameMutex.RUnlock()
+	if err := sendFrame(iface.Name, frame); err != nil {
+		return err
+	}
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: Magic packet sent as an Ethernet frame on %s to %s\n", w.name, iface.Name, w.macAddress)
+	}
+	return nil
+}
+
+// buildEthernetFrame prefixes payload with an Ethernet II header from src to dst with the Wake-on-LAN EtherType
+func buildEthernetFrame(dst, src net.HardwareAddr, payload []byte) []byte {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, dst...)
+	frame = append(frame, src...)
+	frame = append(frame, byte(etherTypeWakeOnLAN>>8), byte(etherTypeWakeOnLAN&0xff))
+	return append(frame, payload...)
+}
+
+// logDryRunWake logs where a wake would be sent without sending it
+func (w *WOLPlugin) logDryRunWake(packet []byte) {
+	if w.wakeMethod != wakeMethodGateway {
+		var targets []string
+		for _, target := range w.wakeTargets() {
+			targets = append(targets, w.targetEndpoint(target.address))
+		}
+
+		repeat := w.packetRepeat
+		if repeat < 1 {
+			repeat = 1
+		}
+		fmt.Printf("WOL Plugin [%s]: Dry run - would send %d-byte magic packet for %s to %s (%d time(s) each)\n",
+			w.name, len(packet), w.macAddress, strings.Join(targets, ", "), repeat)
+	}
+	if w.wakeMethod != wakeMethodLocal {
+		fmt.Printf("WOL Plugin [%s]: Dry run - would ask gateway %s to wake %s\n", w.name, redactURL(w.gatewayURL), w.macAddress)
+	}
+}
+
+// broadcastFallbackNoteLocked describes, for the wake status message, a last send that fell back to the
+// limited broadcast. The caller must hold wakeMutex.
+func (w *WOLPlugin) broadcastFallbackNoteLocked() string {
+	if w.wakeCache.broadcastFallback == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (sent to limited broadcast %s: %s)", limitedBroadcastAddress, w.wakeCache.broadcastFallback)
+}
+
+// sendRepeated sends the WOL packet to an address packetRepeat times, succeeding if any send succeeds.
+// The remaining repeats are skipped once the middleware shuts down.
+func (w *WOLPlugin) sendRepeated(packet []byte, targetAddr string) error {
+	repeat := w.packetRepeat
+	if repeat < 1 {
+		repeat = 1
+	}
+
+	sent := false
+	var lastError error
+	for i := 0; i < repeat; i++ {
+		if i > 0 && w.packetRepeatDelay > 0 && !w.sleep(w.ctx, w.packetRepeatDelay) {
+			break
+		}
+		if err := w.sendPacket(packet, targetAddr); err != nil {
+			lastError = err
+			continue
+		}
+		sent = true
+	}
+
+	if !sent {
+		return lastError
+	}
+	return nil
+}
+
+// resolveTarget resolves a wake target host, bracketing IPv6 literals such as "ff02::1%eth0"
+func (w *WOLPlugin) resolveTarget(targetAddr string) (*net.UDPAddr, error) {
+	host, port, err := splitTargetPort(targetAddr)
+	if err != nil {
+		return nil, err
+	}
+	if port == 0 {
+		port = w.port
+	}
+	if w.wakeTargetResolveTTL > 0 && !isIPLiteral(host) {
+		ipAddr, err := w.resolveHostCached(host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve UDP address %s: %v", targetAddr, err)
+		}
+		return &net.UDPAddr{IP: ipAddr.IP, Port: port, Zone: ipAddr.Zone}, nil
+	}
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve UDP address %s: %v", targetAddr, err)
+	}
+	return addr, nil
+}
+
+// isIPLiteral reports whether host is an IP address, with or without a zone, rather than a hostname
+func isIPLiteral(host string) bool {
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host) != nil
+}
+
+// resolvedHost is a cached wake target hostname lookup
+type resolvedHost struct {
+	addr    *net.IPAddr
+	expires time.Time
+}
+
+// lookupIPAddr is the default lookupIP, using the system resolver
+func lookupIPAddr(host string) (*net.IPAddr, error) {
+	return net.ResolveIPAddr("ip", host)
+}
+
+// resolveHostCached looks host up at most once per wakeTargetResolveTTL. When a refresh fails the previous
+// address is reused, so a DNS hiccup does not abort the wake.
+func (w *WOLPlugin) resolveHostCached(host string) (*net.IPAddr, error) {
+	now := w.now()
+	w.resolveMutex.Lock()
+	cached, ok := w.resolveCache[host]
+	w.resolveMutex.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.addr, nil
+	}
+
+	addr, err := w.lookupIP(host)
+	if err != nil {
+		if !ok {
+			return nil, err
+		}
+		fmt.Printf("WOL Plugin [%s]: Failed to resolve %s (%v); using cached address %s\n", w.name, host, err, cached.addr)
+		return cached.addr, nil
+	}
+
+	w.resolveMutex.Lock()
+	if w.resolveCache == nil {
+		w.resolveCache = make(map[string]resolvedHost)
+	}
+	w.resolveCache[host] = resolvedHost{addr: addr, expires: now.Add(w.wakeTargetResolveTTL)}
+	w.resolveMutex.Unlock()
+	return addr, nil
+}
+
+// splitTargetPort separates an optional port from a wake target such as "192.168.1.255:7" or
+// "[ff02::1%eth0]:7". Bare targets, including unbracketed IPv6 addresses, return port 0.
+func splitTargetPort(target string) (string, int, error) {
+	bracketed := strings.HasPrefix(target, "[") && strings.Contains(target, "]:")
+	if !bracketed && strings.Count(target, ":") != 1 {
+		return strings.TrimSuffix(strings.TrimPrefix(target, "["), "]"), 0, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid target %q: %v", target, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in target %q: must be between 1 and 65535", target)
+	}
+	return host, port, nil
+}
+
+// targetEndpoint formats a wake target with the port it will be sent to, for logging
+func (w *WOLPlugin) targetEndpoint(target string) string {
+	host, port, err := splitTargetPort(target)
+	if err != nil {
+		return target
+	}
+	if port == 0 {
+		port = w.port
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// sendToAddress sends WOL packet to a specific address
+func (w *WOLPlugin) sendToAddress(packet []byte, targetAddr string) error {
+	addr, err := w.resolveTarget(targetAddr)
+	if err != nil {
+		return err
+	}
+
+	laddr, err := w.localUDPAddr(addr)
+	if err != nil {
+		return fmt.Errorf("failed to determine local address for %s: %v", targetAddr, err)
+	}
+
+	if w.reuseWakeConnections {
+		return w.sendOnWakeConnection(packet, targetAddr, laddr, addr)
+	}
+
+	conn, err := net.DialUDP("udp", laddr, addr)
+	if err != nil {
+		return fmt.Errorf("failed to create UDP connection to %s: %v", targetAddr, err)
+	}
+	defer conn.Close()
+
+	// Note: Broadcast is handled by OS defaults for UDP sockets
+
+	_, err = conn.Write(packet)
+	if err != nil {
+		return fmt.Errorf("failed to send packet to %s: %v", targetAddr, err)
+	}
+
+	return nil
+}
+
+// sendOnWakeConnection sends on the connection kept for targetAddr, dialing it on first use. A connection that
+// fails to send is dropped so the next attempt dials afresh. The connections are closed by closeWakeConnections
+// when the wake sequence ends. With sourcePort set only one socket can be bound to the port, so a single
+// unconnected socket per local address is kept instead and every target is sent to from it. The lock is
+// only held to find or create the connection, so parallel sends don't wait for each other.
+func (w *WOLPlugin) sendOnWakeConnection(packet []byte, targetAddr string, laddr, addr *net.UDPAddr) error {
+	shared := laddr != nil && laddr.Port != 0
+	key := targetAddr
+	if shared {
+		key = "from " + laddr.String()
+	}
+	w.wakeConnsMutex.Lock()
+	conn := w.wakeConns[key]
+	if conn == nil {
+		var err error
+		if shared {
+			conn, err = net.ListenUDP("udp", laddr)
+		} else {
+			conn, err = net.DialUDP("udp", laddr, addr)
+		}
+		if err != nil {
+			w.wakeConnsMutex.Unlock()
+			return fmt.Errorf("failed to create UDP connection to %s: %v", targetAddr, err)
+		}
+		if w.wakeConns == nil {
+			w.wakeConns = make(map[string]*net.UDPConn)
+		}
+		w.wakeConns[key] = conn
+	}
+	w.wakeConnsMutex.Unlock()
+
+	var err error
+	if shared {
+		_, err = conn.WriteToUDP(packet, addr)
+	} else {
+		_, err = conn.Write(packet)
+	}
+	if err != nil {
+		w.wakeConnsMutex.Lock()
+		if w.wakeConns[key] == conn {
+			delete(w.wakeConns, key)
+		}
+		w.wakeConnsMutex.Unlock()
+		conn.Close()
+		return fmt.Errorf("failed to send packet to %s: %v", targetAddr, err)
+	}
+	return nil
+}
+
+// closeWakeConnections closes the connections kept under reuseWakeConnections; it is a no-op otherwise
+func (w *WOLPlugin) closeWakeConnections() {
+	w.wakeConnsMutex.Lock()
+	defer w.wakeConnsMutex.Unlock()
+	for target, conn := range w.wakeConns {
+		conn.Close()
+		delete(w.wakeConns, target)
+	}
+}
+
+// localUDPAddr returns the local address to bind for sending to target, or nil to let the OS choose.
+// When a network interface is configured its address matching the target's family is used.
+func (w *WOLPlugin) localUDPAddr(target *net.UDPAddr) (*net.UDPAddr, error) {
+	if w.networkInterface == "" && w.sourcePort == 0 {
+		return nil, nil
+	}
+
+	laddr := &net.UDPAddr{Port: w.sourcePort}
+	if w.networkInterface == "" {
+		return laddr, nil
+	}
+
+	iface, err := w.interfaceByName(w.networkInterface)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s not found: %v", w.networkInterface, err)
+	}
+
+	addrs, err := w.interfaces.Addrs(iface)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses for interface %s: %v", w.networkInterface, err)
+	}
+
+	wantIPv4 := target.IP.To4() != nil
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if (ipNet.IP.To4() != nil) == wantIPv4 {
+			laddr.IP = ipNet.IP
+			if ipNet.IP.IsLinkLocalUnicast() && !wantIPv4 {
+				laddr.Zone = iface.Name
+			}
+			return laddr, nil
+		}
+	}
+
+	return nil, fmt.Errorf("interface %s has no address matching %s", w.networkInterface, target.IP)
+}
+
+func (w *WOLPlugin) parseMACAddress(macStr string) ([]byte, error) {
+	macStr = strings.ReplaceAll(macStr, ":", "")
+	macStr = strings.ReplaceAll(macStr, "-", "")
+	macStr = strings.ReplaceAll(macStr, ".", "")
+	macStr = strings.ToLower(macStr)
+
+	if len(macStr) != 12 {
+		return nil, fmt.Errorf("MAC address must be 12 hex characters")
+	}
+
+	macBytes := make([]byte, 6)
+	for i := 0; i < 6; i++ {
+		b, err := strconv.ParseUint(macStr[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex in MAC address: %v", err)
+		}
+		macBytes[i] = byte(b)
+	}
+
+	return macBytes, nil
+}
+
+// Magic packet layouts selected by packetFormat
+const (
+	// packetFormatStandard is six 0xFF bytes followed by the MAC repeated 16 times, 102 bytes in all
+	packetFormatStandard = "standard"
+	// packetFormatSecureOn appends a 4 or 6 byte SecureOn password to the standard packet, for NICs that
+	// only wake when it matches the one set in their firmware
+	packetFormatSecureOn = "secureon"
+)
+
+// createMagicPacket builds the magic packet for macBytes in the configured packetFormat
+func (w *WOLPlugin) createMagicPacket(macBytes []byte) []byte {
+	packet := make([]byte, 102, 102+len(w.secureOnPassword))
+
+	for i := 0; i < 6; i++ {
+		packet[i] = 0xFF
+	}
+
+	for i := 0; i < 16; i++ {
+		copy(packet[6+i*6:], macBytes)
+	}
+
+	if w.packetFormat == packetFormatSecureOn {
+		packet = append(packet, w.secureOnPassword...)
+	}
+	return packet
+}
+
+// parseSecureOnPassword parses a SecureOn password written as a dotted IPv4 address ("192.168.1.1", 4 bytes) or
+// as hex bytes in any MAC address notation (6 bytes, or 4)
+func parseSecureOnPassword(value string) ([]byte, error) {
+	if ip := net.ParseIP(value); ip != nil && ip.To4() != nil && strings.Count(value, ".") == 3 {
+		return []byte(ip.To4()), nil
+	}
+	digits := strings.NewReplacer(":", "", "-", "", ".", "").Replace(value)
+	password, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex: %v", err)
+	}
+	if len(password) != 4 && len(password) != 6 {
+		return nil, fmt.Errorf("must be 4 or 6 bytes, got %d", len(password))
+	}
+	return password, nil
+}
+
+func (w *WOLPlugin) waitForService(forwarded http.Header) bool {
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: Waiting for service to come online (timeout: %v)\n", w.name, w.timeout)
+	}
+	
+	start := w.now()
+	healthyProbes := 0
+	for probe := 1; w.now().Sub(start) < w.timeout; probe++ {
+		if live, ready := w.checkServiceReady(forwarded); live && ready {
+			if healthyProbes++; healthyProbes >= w.wakeHealthyThreshold {
+				return true
+			}
+		} else {
+			healthyProbes = 0
+		}
+		if !w.sleep(w.ctx, w.wakePollDelay(probe)) {
+			return false
+		}
+	}
+	return false
+}
+
+// defaultWakePollInterval is how often the service is probed while waiting for it to come up
+const defaultWakePollInterval = 2 * time.Second
+
+// wakePollDelay returns the pause after the given (1-based) failed probe of a wait: wakePollInterval,
+// doubling with every probe up to wakePollMaxInterval
+func (w *WOLPlugin) wakePollDelay(probe int) time.Duration {
+	delay := w.wakePollInterval
+	for i := 1; i < probe && delay < w.wakePollMaxInterval; i++ {
+		delay *= 2
+	}
+	if delay > w.wakePollMaxInterval {
+		delay = w.wakePollMaxInterval
+	}
+	return delay
+}
+
+// Bounds for statusPollIntervalMs, keeping the page responsive without flooding the middleware with polls
+const (
+	defaultStatusPollIntervalMs = 2000
+	minStatusPollIntervalMs     = 500
+	maxStatusPollIntervalMs     = 30000
+	// defaultStatusPollMaxIntervalMs caps the backed-off poll interval when statusPollBackoff is set
+	defaultStatusPollMaxIntervalMs = 15000
+	maxStatusPollBackoff           = 4.0
+)
+
+// controlPageData holds the fields available to the control page template, including custom templates
+type controlPageData struct {
+	Title                string
+	ServiceDescription   string
+	TimeoutSeconds       int
+	AutoRedirect         bool
+	RedirectDelaySeconds int
+	// AutoRedirectStablePolls is how many healthy status polls in a row the page waits for before redirecting
+	AutoRedirectStablePolls int
+	// StatusPollIntervalMs is how often the page polls /_wol/status when it cannot stream events
+	StatusPollIntervalMs int
+	// StatusPollMaxIntervalMs and StatusPollBackoff let the poll interval grow while the status stays the same;
+	// a backoff of 1 keeps it constant
+	StatusPollMaxIntervalMs int
+	StatusPollBackoff    float64
+	ConfirmPowerOff      bool
+	// PowerOffConfirmMessage is the configured confirmation text, or the translated default
+	PowerOffConfirmMessage string
+	// PowerOffRequireTyping asks the user to type ServiceDescription before powering off
+	PowerOffRequireTyping bool
+	// ShowWakeButton and ShowPowerOffButton are cleared for read-only clients on each render
+	ShowWakeButton       bool
+	ShowPowerOffButton   bool
+	HideRedirectButton   bool
+	// TargetMAC and TargetIP identify the machine being woken; set only under showTargetDetails for
+	// allowedControlIPs clients that are not read-only
+	TargetMAC            string
+	TargetIP             string
+	CustomCSS            template.CSS
+	LogoURL              string
+	Language             string
+	CSRFToken            string
+	// CSPNonce authorizes the page's inline <style> and <script> blocks under controlPageSecurityHeaders
+	CSPNonce             string
+	// OriginalURL is the path and query the user requested before the control page was shown
+	OriginalURL          string
+	Text                 controlPageStrings
+}
+
+// controlPageStrings holds the translatable control page text, serialized for use by the embedded JS
+type controlPageStrings struct {
+	StatusOffline        string `json:"statusOffline"`
+	StatusOnline         string `json:"statusOnline"`
+	StatusRedirecting    string `json:"statusRedirecting"`
+	StatusWaking         string `json:"statusWaking"`
+	StatusPoweringOff    string `json:"statusPoweringOff"`
+	StatusLastError      string `json:"statusLastError"`
+	DetailsWaking        string `json:"detailsWaking"`
+	DetailsPoweringOff   string `json:"detailsPoweringOff"`
+	DetailsTiming        string `json:"detailsTiming"`
+	ButtonWake           string `json:"buttonWake"`
+	ButtonOnline         string `json:"buttonOnline"`
+	ButtonWaking         string `json:"buttonWaking"`
+	ButtonPowerOff       string `json:"buttonPowerOff"`
+	ButtonPoweringOff    string `json:"buttonPoweringOff"`
+	ButtonRedirect       string `json:"buttonRedirect"`
+	ErrorWakeFailed      string `json:"errorWakeFailed"`
+	ErrorWakeRequest     string `json:"errorWakeRequest"`
+	ErrorPowerOffFailed  string `json:"errorPowerOffFailed"`
+	ErrorPowerOffRequest string `json:"errorPowerOffRequest"`
+	ConfirmPowerOff      string `json:"confirmPowerOff"`
+	ConfirmTypeName      string `json:"confirmTypeName"`
+	ConfirmTypeMismatch  string `json:"confirmTypeMismatch"`
+	MaintenanceHeading   string `json:"maintenanceHeading"`
+	MaintenanceMessage   string `json:"maintenanceMessage"`
+}
+
+// defaultLanguage is used when no language or an unsupported one is configured
+const defaultLanguage = "en"
+
+// controlPageTranslations maps language codes to control page text.
+// StatusRedirecting uses a {seconds} placeholder and StatusLastError a {reason} placeholder filled in by the page script.
+var controlPageTranslations = map[string]controlPageStrings{
+	"en": {
+		StatusOffline:        "Service is currently offline",
+		StatusOnline:         "Service is online and ready!",
+		StatusRedirecting:    "Service is online! Redirecting in {seconds} seconds...",
+		StatusWaking:         "Waking up service...",
+		StatusPoweringOff:    "Powering off service...",
+		StatusLastError:      "Last attempt failed: {reason}",
+		DetailsWaking:        "Wake process in progress...",
+		DetailsPoweringOff:   "Power-off process in progress...",
+		DetailsTiming:        "{elapsed}s elapsed, about {eta}s remaining",
+		ButtonWake:           "🚀 Turn On Service",
+		ButtonOnline:         "✅ Service Online",
+		ButtonWaking:         "⏳ Waking Up...",
+		ButtonPowerOff:       "⏻ Power Off",
+		ButtonPoweringOff:    "⏳ Powering Off...",
+		ButtonRedirect:       "↗️ Go to Service",
+		ErrorWakeFailed:      "Failed to start wake process",
+		ErrorWakeRequest:     "Error starting wake process",
+		ErrorPowerOffFailed:  "Failed to start power-off process",
+		ErrorPowerOffRequest: "Error starting power-off process",
+		ConfirmPowerOff:      "Are you sure you want to power off the service?",
+		ConfirmTypeName:      "Type \"{name}\" to confirm:",
+		ConfirmTypeMismatch:  "The name did not match. Power-off cancelled.",
+		MaintenanceHeading:   "Scheduled maintenance",
+		MaintenanceMessage:   "This service is down for scheduled maintenance. Please try again later.",
+	},
+	"de": {
+		StatusOffline:        "Dienst ist derzeit offline",
+		StatusOnline:         "Dienst ist online und bereit!",
+		StatusRedirecting:    "Dienst ist online! Weiterleitung in {seconds} Sekunden...",
+		StatusWaking:         "Dienst wird aufgeweckt...",
+		StatusPoweringOff:    "Dienst wird ausgeschaltet...",
+		StatusLastError:      "Letzter Versuch fehlgeschlagen: {reason}",
+		DetailsWaking:        "Aufweckvorgang läuft...",
+		DetailsPoweringOff:   "Ausschaltvorgang läuft...",
+		DetailsTiming:        "{elapsed}s vergangen, noch etwa {eta}s",
+		ButtonWake:           "🚀 Dienst einschalten",
+		ButtonOnline:         "✅ Dienst online",
+		ButtonWaking:         "⏳ Wird aufgeweckt...",
+		ButtonPowerOff:       "⏻ Ausschalten",
+		ButtonPoweringOff:    "⏳ Wird ausgeschaltet...",
+		ButtonRedirect:       "↗️ Zum Dienst",
+		ErrorWakeFailed:      "Aufweckvorgang konnte nicht gestartet werden",
+		ErrorWakeRequest:     "Fehler beim Starten des Aufweckvorgangs",
+		ErrorPowerOffFailed:  "Ausschaltvorgang konnte nicht gestartet werden",
+		ErrorPowerOffRequest: "Fehler beim Starten des Ausschaltvorgangs",
+		ConfirmPowerOff:      "Möchten Sie den Dienst wirklich ausschalten?",
+		ConfirmTypeName:      "Geben Sie \"{name}\" zur Bestätigung ein:",
+		ConfirmTypeMismatch:  "Der Name stimmt nicht überein. Ausschalten abgebrochen.",
+		MaintenanceHeading:   "Geplante Wartung",
+		MaintenanceMessage:   "Dieser Dienst ist wegen geplanter Wartungsarbeiten nicht verfügbar. Bitte versuchen Sie es später erneut.",
+	},
+	"fr": {
+		StatusOffline:        "Le service est actuellement hors ligne",
+		StatusOnline:         "Le service est en ligne et prêt !",
+		StatusRedirecting:    "Le service est en ligne ! Redirection dans {seconds} secondes...",
+		StatusWaking:         "Réveil du service...",
+		StatusPoweringOff:    "Arrêt du service...",
+		StatusLastError:      "Dernière tentative échouée : {reason}",
+		DetailsWaking:        "Réveil en cours...",
+		DetailsPoweringOff:   "Arrêt en cours...",
+		DetailsTiming:        "{elapsed} s écoulées, environ {eta} s restantes",
+		ButtonWake:           "🚀 Allumer le service",
+		ButtonOnline:         "✅ Service en ligne",
+		ButtonWaking:         "⏳ Réveil en cours...",
+		ButtonPowerOff:       "⏻ Éteindre",
+		ButtonPoweringOff:    "⏳ Arrêt en cours...",
+		ButtonRedirect:       "↗️ Accéder au service",
+		ErrorWakeFailed:      "Impossible de démarrer le réveil",
+		ErrorWakeRequest:     "Erreur lors du démarrage du réveil",
+		ErrorPowerOffFailed:  "Impossible de démarrer l'arrêt",
+		ErrorPowerOffRequest: "Erreur lors du démarrage de l'arrêt",
+		ConfirmPowerOff:      "Voulez-vous vraiment éteindre le service ?",
+		ConfirmTypeName:      "Saisissez « {name} » pour confirmer :",
+		ConfirmTypeMismatch:  "Le nom ne correspond pas. Arrêt annulé.",
+		MaintenanceHeading:   "Maintenance planifiée",
+		MaintenanceMessage:   "Ce service est indisponible pour une maintenance planifiée. Veuillez réessayer plus tard.",
+	},
+}
+
+// resolveLanguage maps a configured language such as "de-DE" to a supported code, falling back to English
+func resolveLanguage(language string) string {
+	code := strings.ToLower(strings.TrimSpace(language))
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i]
+	}
+	if _, ok := controlPageTranslations[code]; ok {
+		return code
+	}
+	return defaultLanguage
+}
+
+// loadControlPageTemplate parses the embedded control page template or a configured replacement
+func loadControlPageTemplate(config *Config) (*template.Template, error) {
+	source := controlPageTemplate
+	switch {
+	case config.ControlPageTemplateInline != "" && config.ControlPageTemplatePath != "":
+		return nil, fmt.Errorf("controlPageTemplateInline and controlPageTemplatePath are mutually exclusive")
+	case config.ControlPageTemplateInline != "":
+		source = config.ControlPageTemplateInline
+	case config.ControlPageTemplatePath != "":
+		content, err := os.ReadFile(config.ControlPageTemplatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read controlPageTemplatePath: %v", err)
+		}
+		source = string(content)
+	}
+
+	tmpl, err := template.New("controlPage").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid control page template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// pageTextData is what controlPageTitle and serviceDescription templates are evaluated against
+type pageTextData struct {
+	// Host is the host the client asked for, from X-Forwarded-Host when trustForwardedFor is set
+	Host string
+	// Name is the middleware name
+	Name string
+	Now  time.Time
+}
+
+// parsePageTextTemplate parses a controlPageTitle or serviceDescription that uses template syntax, returning
+// nil for plain text. A trial run against sample data catches references to fields that do not exist.
+func parsePageTextTemplate(field, text string) (*texttemplate.Template, error) {
+	if !strings.Contains(text, "{{") {
+		return nil, nil
+	}
+	tmpl, err := texttemplate.New(field).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", field, err)
+	}
+	if err := tmpl.Execute(io.Discard, pageTextData{Host: "example.com", Name: "wol", Now: time.Now()}); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", field, err)
+	}
+	return tmpl, nil
+}
+
+// renderPageText evaluates a page text template for req, or returns the configured text when it is plain or
+// the template fails. The result is escaped by the page template like any other text.
+func (w *WOLPlugin) renderPageText(tmpl *texttemplate.Template, text string, req *http.Request) string {
+	if tmpl == nil {
+		return text
+	}
+	data := pageTextData{Host: req.Host, Name: w.name, Now: w.now()}
+	if w.trustForwardedFor {
+		if host := lastForwardedValue(req, "X-Forwarded-Host"); host != "" {
+			data.Host = host
+		}
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Failed to render %s: %v\n", w.name, tmpl.Name(), err)
+		}
+		return text
+	}
+	return out.String()
+}
+
+// sanitizeCustomCSS marks operator-supplied CSS as trusted while preventing it from closing its <style> block
+func sanitizeCustomCSS(css string) template.CSS {
+	return template.CSS(strings.ReplaceAll(css, "</", "<\\/"))
+}
+
+// maintenancePageData holds the fields available to the maintenance page template
+type maintenancePageData struct {
+	Title              string
+	ServiceDescription string
+	// Message is the configured maintenanceMessage, or the translated default
+	Message   string
+	CustomCSS template.CSS
+	LogoURL   string
+	Language  string
+	Text      controlPageStrings
+}
+
+// serveMaintenancePage answers with the maintenance page and 503 Service Unavailable
+func (w *WOLPlugin) serveMaintenancePage(rw http.ResponseWriter, req *http.Request) {
+	data := maintenancePageData{
+		Title:              w.renderPageText(w.controlPageTitleTmpl, w.controlPageTitle, req),
+		ServiceDescription: w.renderPageText(w.serviceDescriptionTmpl, w.serviceDescription, req),
+		Message:            w.maintenanceMessage,
+		CustomCSS:          w.controlPageCustomCSS,
+		LogoURL:            w.controlPageLogoURL,
+		Language:           w.language,
+		Text:               controlPageTranslations[w.language],
+	}
+	if data.Message == "" {
+		data.Message = data.Text.MaintenanceMessage
+	}
+
+	var page bytes.Buffer
+	if err := w.maintenancePageTmpl.Execute(&page, data); err != nil {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Maintenance page template execution failed: %v\n", w.name, err)
+		}
+		http.Error(rw, "Template execution error", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(http.StatusServiceUnavailable)
+	rw.Write(page.Bytes())
+}
+
+// serveControlPage renders and serves the control page
+func (w *WOLPlugin) serveControlPage(rw http.ResponseWriter, req *http.Request) {
+	if w.contentNegotiation {
+		rw.Header().Add("Vary", "Accept")
+		if !acceptsHTML(req) {
+			w.serveControlJSON(rw, req)
+			return
+		}
+	}
+
+	data := controlPageData{
+		Title:                w.renderPageText(w.controlPageTitleTmpl, w.controlPageTitle, req),
+		ServiceDescription:   w.renderPageText(w.serviceDescriptionTmpl, w.serviceDescription, req),
+		TimeoutSeconds:       int(w.timeout.Seconds()),
+		AutoRedirect:         w.autoRedirect,
+		RedirectDelaySeconds: int(w.redirectDelay.Seconds()),
+		AutoRedirectStablePolls: w.autoRedirectStablePolls,
+		StatusPollIntervalMs: w.statusPollIntervalMs,
+		StatusPollMaxIntervalMs: w.statusPollMaxIntervalMs,
+		StatusPollBackoff:    w.statusPollBackoff,
+		ConfirmPowerOff:      w.confirmPowerOff,
+		PowerOffConfirmMessage: w.powerOffConfirmMessage,
+		PowerOffRequireTyping:  w.powerOffRequireTyping,
+		ShowWakeButton:       true,
+		ShowPowerOffButton:   w.showPowerOffButton,
+		HideRedirectButton:   w.hideRedirectButton,
+		CustomCSS:            w.controlPageCustomCSS,
+		LogoURL:              w.controlPageLogoURL,
+		Language:             w.language,
+		OriginalURL:          req.URL.RequestURI(),
+		Text:                 controlPageTranslations[w.language],
+	}
+	if data.PowerOffConfirmMessage == "" {
+		data.PowerOffConfirmMessage = data.Text.ConfirmPowerOff
+	}
+	if w.isReadOnlyClient(req) {
+		data.ShowWakeButton = false
+		data.ShowPowerOffButton = false
+	} else if w.showTargetDetails && len(w.allowedControlIPs) > 0 && w.controlIPAllowed(req) {
+		data.TargetMAC = w.macAddress
+		data.TargetIP = w.ipAddress
+	}
+
+	if w.enableCSRFProtection {
+		token, err := w.csrfToken(rw, req)
+		if err != nil {
+			fmt.Printf("WOL Plugin [%s]: Failed to generate CSRF token: %v\n", w.name, err)
+			http.Error(rw, "Failed to generate CSRF token", http.StatusInternalServerError)
+			return
+		}
+		data.CSRFToken = token
+	}
+
+	if w.controlPageSecurityHeaders {
+		nonce, err := newCSPNonce()
+		if err != nil {
+			fmt.Printf("WOL Plugin [%s]: Failed to generate CSP nonce: %v\n", w.name, err)
+			http.Error(rw, "Failed to generate CSP nonce", http.StatusInternalServerError)
+			return
+		}
+		data.CSPNonce = nonce
+	}
+
+	// Render into a buffer so a failing custom template doesn't leave a half-written page
+	var page bytes.Buffer
+	if err := w.controlPageTmpl.Execute(&page, data); err != nil {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Control page template execution failed: %v\n", w.name, err)
+		}
+		http.Error(rw, "Template execution error", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if w.controlPageSecurityHeaders {
+		w.setControlPageSecurityHeaders(rw.Header(), data.CSPNonce)
+	}
+	rw.Write(page.Bytes())
+}
+
+// defaultControlPageCSP admits the control page's own inline assets by nonce and its requests to /_wol/
+const defaultControlPageCSP = "default-src 'self'; script-src 'nonce-{nonce}'; style-src 'nonce-{nonce}'; img-src * data:; connect-src 'self'; base-uri 'none'; object-src 'none'; frame-ancestors 'none'"
+
+// cspNonceBytes is the size of the random nonce issued with each control page
+const cspNonceBytes = 16
+
+// newCSPNonce returns a fresh nonce for the control page's Content-Security-Policy. The URL-safe alphabet
+// needs no escaping in the template's nonce attributes.
+func newCSPNonce() (string, error) {
+	raw := make([]byte, cspNonceBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// setControlPageSecurityHeaders adds the controlPageSecurityHeaders set, binding the policy to nonce
+func (w *WOLPlugin) setControlPageSecurityHeaders(header http.Header, nonce string) {
+	if w.controlPageCSP != "" {
+		header.Set("Content-Security-Policy", strings.ReplaceAll(w.controlPageCSP, "{nonce}", nonce))
+	}
+	header.Set("X-Frame-Options", "DENY")
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Referrer-Policy", "same-origin")
+}
+
+// acceptsHTML reports whether a request looks like it comes from a browser. An explicit text/html wins;
+// otherwise asking for JSON or sending X-Requested-With marks an API client, and anything else, including
+// a missing Accept header, keeps getting HTML.
+func acceptsHTML(req *http.Request) bool {
+	accept := strings.ToLower(req.Header.Get("Accept"))
+	if strings.Contains(accept, "text/html") || strings.Contains(accept, "application/xhtml+xml") {
+		return true
+	}
+	if strings.Contains(accept, "application/json") || strings.Contains(accept, "+json") {
+		return false
+	}
+	return req.Header.Get("X-Requested-With") == ""
+}
+
+// serveControlJSON answers a non-browser client in place of the control page with the status payload and
+// the endpoints it may call
+func (w *WOLPlugin) serveControlJSON(rw http.ResponseWriter, req *http.Request) {
+	body := w.statusResponse()
+
+	readOnly := w.isReadOnlyClient(req)
+	actions := []map[string]string{
+		{"name": "status", "method": http.MethodGet, "url": "/_wol/status"},
+	}
+	if !readOnly {
+		actions = append(actions, map[string]string{"name": "wake", "method": http.MethodPost, "url": "/_wol/wake"})
+		if w.showPowerOffButton {
+			actions = append(actions, map[string]string{"name": "powerOff", "method": http.MethodPost, "url": "/_wol/poweroff"})
+		}
+		actions = append(actions, map[string]string{"name": "cancel", "method": http.MethodPost, "url": "/_wol/cancel"})
+	}
+	body["actions"] = actions
+
+	if w.enableCSRFProtection && !readOnly {
+		token, err := w.csrfToken(rw, req)
+		if err != nil {
+			fmt.Printf("WOL Plugin [%s]: Failed to generate CSRF token: %v\n", w.name, err)
+			http.Error(rw, "Failed to generate CSRF token", http.StatusInternalServerError)
+			return
+		}
+		body["csrfToken"] = token
+	}
+
+	status := http.StatusOK
+	if body["isHealthy"] != true {
+		status = http.StatusServiceUnavailable
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(body)
+}
+
+// handleWakeEndpoint handles POST requests to /_wol/wake
+func (w *WOLPlugin) handleWakeEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !w.validCSRFToken(req) {
+		w.writeOperationError(rw, errCSRFInvalid)
+		return
+	}
+
+	if w.maintenanceMode {
+		w.writeOperationError(rw, errMaintenance)
+		return
+	}
+
+	if force, _ := strconv.ParseBool(req.FormValue("force")); force {
+		// Forcing throws away another operation, so it needs the admin token when one is configured
+		if w.adminToken != "" && !w.validAdminToken(req) {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="wol-admin"`)
+			w.writeOperationError(rw, &operationError{
+				code:    codeUnauthorized,
+				status:  http.StatusUnauthorized,
+				message: "forced wakes require the admin token",
+			})
+			return
+		}
+		fmt.Printf("WOL Plugin [%s]: Forced wake requested from %s\n", w.name, w.clientIP(req))
+		w.forceResetOperation()
+	}
+
+	ctx := contextWithHealthHeaders(req.Context(), w.forwardedHealthHeaders(req))
+	err := w.Wake(contextWithSpan(ctx, w.startSpan("wol.wake", req)))
+	w.audit(req, auditActionWake, err)
+	if err != nil {
+		w.writeOperationError(rw, err)
+		return
+	}
+
+	w.writeJSONResponse(rw, map[string]interface{}{
+		"success": true,
+		"message": "Wake process started",
+	})
+}
+
+// Machine-readable codes returned by the control endpoints when an operation cannot be started
+const (
+	codeAlreadyRunning = "ALREADY_RUNNING"
+	codeSendFailed     = "SEND_FAILED"
+	codeCSRFInvalid    = "CSRF_INVALID"
+	codeNotRunning     = "NOT_RUNNING"
+	codeIPNotAllowed   = "IP_NOT_ALLOWED"
+	codeRateLimited    = "RATE_LIMITED"
+	codeUnauthorized   = "UNAUTHORIZED"
+	codeMaintenance    = "MAINTENANCE"
+	codeTooManyOperations = "TOO_MANY_OPERATIONS"
+	codeReadOnly       = "READ_ONLY"
+)
+
+// errReadOnly rejects wakes, power-offs and cancellations from clients matched by readOnlyControlIPs or readOnlyRoles
+var errReadOnly = &operationError{
+	code:    codeReadOnly,
+	status:  http.StatusForbidden,
+	message: "This client may only view the service status",
+}
+
+// errIPNotAllowed rejects control requests from clients outside allowedControlIPs
+var errIPNotAllowed = &operationError{
+	code:    codeIPNotAllowed,
+	status:  http.StatusForbidden,
+	message: "Client IP is not allowed to use control endpoints",
+}
+
+// errMaintenance rejects wakes and power-offs while maintenanceMode is on
+var errMaintenance = &operationError{
+	code:    codeMaintenance,
+	status:  http.StatusServiceUnavailable,
+	message: "Service is in maintenance mode",
+}
+
+// errTooManyOperations rejects wakes and power-offs while maxConcurrentOperations sequences are still running
+var errTooManyOperations = &operationError{
+	code:       codeTooManyOperations,
+	status:     http.StatusTooManyRequests,
+	message:    "Too many operations in progress, retry shortly",
+	retryAfter: time.Second,
+}
+
+// errCSRFInvalid rejects control requests that don't carry the control page's CSRF token
+var errCSRFInvalid = &operationError{
+	code:    codeCSRFInvalid,
+	status:  http.StatusForbidden,
+	message: "Missing or invalid CSRF token",
+}
+
+// operationError describes why a wake or power-off could not be started
+type operationError struct {
+	code       string
+	status     int
+	message    string
+	retryAfter time.Duration // sent as a Retry-After header when set
+}
+
+func (e *operationError) Error() string {
+	return e.message
+}
+
+// Is matches the exported errors that Wake and PowerOff callers can test for with errors.Is
+func (e *operationError) Is(target error) bool {
+	switch target {
+	case ErrAlreadyRunning:
+		return e.code == codeAlreadyRunning
+	case ErrRateLimited:
+		return e.code == codeRateLimited
+	case ErrSendFailed:
+		return e.code == codeSendFailed
+	case ErrMaintenance:
+		return e.code == codeMaintenance
+	case ErrTooManyOperations:
+		return e.code == codeTooManyOperations
+	}
+	return false
+}
+
+// Errors reported by Wake and PowerOff, to be checked with errors.Is
+var (
+	ErrAlreadyRunning = errors.New("a wake or power-off is already in progress")
+	ErrRateLimited    = errors.New("wake attempts are suspended after repeated failures")
+	ErrSendFailed     = errors.New("the magic packet could not be sent")
+	ErrMaintenance    = errors.New("the service is in maintenance mode")
+	ErrTooManyOperations = errors.New("too many wake or power-off sequences are still running")
+)
+
+// Wake starts waking the service the way POST /_wol/wake does, for code embedding the plugin. It sends the
+// first magic packet before returning and continues the sequence in the background; ctx only bounds the start.
+// The returned error matches ErrAlreadyRunning, ErrRateLimited, ErrSendFailed, ErrMaintenance or
+// ErrTooManyOperations when the wake cannot start.
+func (w *WOLPlugin) Wake(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if w.maintenanceMode {
+		return errMaintenance
+	}
+	span := spanFromContext(ctx)
+	if span == nil {
+		span = w.startSpan("wol.wake", nil)
+	}
+	return w.startWake(span, healthHeadersFromContext(ctx))
+}
+
+// WakeEvent describes a wake sequence to the callbacks registered with SetWakeHooks
+type WakeEvent struct {
+	Attempts int           // magic packet attempts made so far
+	Duration time.Duration // time since the wake started
+	Message  string        // the status message, describing the failure for OnWakeFailure
+}
+
+// WakeHooks are optional callbacks for code embedding the plugin, run when a wake starts, brings the service
+// up, or ends without it. They run on the wake's goroutine without any plugin lock held, so they may call
+// back into the plugin, but a slow hook delays the rest of the sequence.
+type WakeHooks struct {
+	OnWakeStart   func(WakeEvent)
+	OnWakeSuccess func(WakeEvent)
+	OnWakeFailure func(WakeEvent)
+}
+
+// SetWakeHooks replaces the wake lifecycle callbacks; nil fields are skipped
+func (w *WOLPlugin) SetWakeHooks(hooks WakeHooks) {
+	w.wakeHooksMutex.Lock()
+	w.wakeHooks = hooks
+	w.wakeHooksMutex.Unlock()
+}
+
+// SetFrameSender replaces how raw Ethernet frames are sent for wakeTransport "ethernet", for code embedding the
+// plugin outside Traefik's interpreter where it can open a layer-2 socket. send receives the interface name and
+// the complete frame; nil restores the default, which reports the frames as unsupported so wakes use UDP.
+func (w *WOLPlugin) SetFrameSender(send func(ifaceName string, frame []byte) error) {
+	if send == nil {
+		send = sendRawFrame
+	}
+	w.sendFrameMutex.Lock()
+	w.sendFrame = send
+	w.sendFrameMutex.Unlock()
+}
+
+// currentWakeHooks returns the callbacks registered with SetWakeHooks
+func (w *WOLPlugin) currentWakeHooks() WakeHooks {
+	w.wakeHooksMutex.RLock()
+	defer w.wakeHooksMutex.RUnlock()
+	return w.wakeHooks
+}
+
+// runWakeHook calls hook if it is set. A panicking hook is logged rather than taking the wake sequence
+// down with it.
+func (w *WOLPlugin) runWakeHook(hook func(WakeEvent), event WakeEvent) {
+	if hook == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("WOL Plugin [%s]: Wake hook panicked: %v\n", w.name, r)
+		}
+	}()
+	hook(event)
+}
+
+// PowerOff starts the power-off sequence in the background the way POST /_wol/poweroff does. The returned
+// error matches ErrAlreadyRunning while another wake or power-off is in progress, ErrMaintenance or
+// ErrTooManyOperations.
+func (w *WOLPlugin) PowerOff(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if w.maintenanceMode {
+		return errMaintenance
+	}
+	return w.startPowerOff()
+}
+
+// writeOperationError writes a failed operation as JSON with its code and HTTP status
+func (w *WOLPlugin) writeOperationError(rw http.ResponseWriter, err error) {
+	opErr, ok := err.(*operationError)
+	if !ok {
+		opErr = &operationError{code: codeSendFailed, status: http.StatusInternalServerError, message: err.Error()}
+	}
+
+	if opErr.retryAfter > 0 {
+		rw.Header().Set("Retry-After", retryAfterSeconds(opErr.retryAfter))
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(opErr.status)
+	json.NewEncoder(rw).Encode(map[string]interface{}{
+		"success": false,
+		"code":    opErr.code,
+		"message": opErr.message,
+	})
+}
+
+// startWake sends the first magic packet and continues the wake sequence in the background.
+// It fails without side effects if another operation is running or the packet cannot be sent.
+// The sequence is recorded under span, which startWake finishes.
+func (w *WOLPlugin) startWake(span *traceSpan, forwarded http.Header) error {
+	span.setAttribute("wol.mac", w.macAddress)
+	w.wakeMutex.Lock()
+	if w.wakeCache.isWaking || w.wakeCache.isPoweringOff {
+		processType := "wake"
+		if w.wakeCache.isPoweringOff {
+			processType = "power-off"
+		}
+		w.wakeMutex.Unlock()
+		span.setError(processType + " process already in progress")
+		span.finish()
+		return &operationError{
+			code:    codeAlreadyRunning,
+			status:  http.StatusConflict,
+			message: fmt.Sprintf("%s process already in progress", processType),
+		}
+	}
+	if !w.acquireOperationSlot() {
+		w.wakeMutex.Unlock()
+		span.setError(errTooManyOperations.message)
+		span.finish()
+		return errTooManyOperations
+	}
+
+	if retryAfter, ok := w.allowWakeAttempt(); !ok {
+		w.releaseOperationSlot()
+		w.wakeMutex.Unlock()
+		span.setError("wake attempts suspended by circuit breaker")
+		span.finish()
+		return &operationError{
+			code:       codeRateLimited,
+			status:     http.StatusTooManyRequests,
+			message:    fmt.Sprintf("Wake attempts are suspended after repeated failures, retry in %v", retryAfter.Round(time.Second)),
+			retryAfter: retryAfter,
+		}
+	}
+
+	w.wakeCache.isWaking = true
+	w.wakeCache.isPoweringOff = false
+	w.wakeCache.startTime = w.now()
+	w.wakeCache.message = fmt.Sprintf("Wake attempt 1/%d - Sending WOL packet...", w.retryAttempts)
+	w.wakeCache.progress = 0
+	ctx := contextWithHealthHeaders(w.beginOperationLocked(), forwarded)
+	slot := w.holdOperationSlotLocked()
+	w.notifyWakeChangeLocked()
+	w.wakeMutex.Unlock()
+
+	// The pre-wake webhook can take a while, so the sequence calls it and sends every packet in the background
+	if w.preWakeWebhookURL != "" {
+		go func() {
+			defer slot.release()
+			w.performWakeSequence(contextWithSpan(ctx, span), false)
+		}()
+		return nil
+	}
+
+	// Send the first packet synchronously so callers learn about send failures immediately
+	if err := w.sendWOLPacketTraced(span, 1); err != nil {
+		fmt.Printf("WOL Plugin [%s]: Failed to send WOL packet: %v\n", w.name, err)
+		w.wakeMutex.Lock()
+		w.closeWakeConnections()
+		w.wakeCache.isWaking = false
+		w.wakeCache.message = fmt.Sprintf("Failed to send WOL packet: %v", err)
+		w.recordWakeFailureLocked(err.Error())
+		w.endOperationLocked()
+		w.notifyWakeChangeLocked()
+		w.wakeMutex.Unlock()
+		slot.release()
+		w.recordWakeResult(ctx, false)
+		span.setAttribute("wol.attempts", 1)
+		span.setError(err.Error())
+		span.finish()
+		return &operationError{
+			code:    codeSendFailed,
+			status:  http.StatusBadGateway,
+			message: fmt.Sprintf("failed to send WOL packet: %v", err),
+		}
+	}
+
+	// Start wake process in background
+	go func() {
+		defer slot.release()
+		w.performWakeSequence(contextWithSpan(ctx, span), true)
+	}()
+	return nil
+}
+
+// errCancelledByUser is the cancellation cause recorded when /_wol/cancel aborts an operation
+var errCancelledByUser = errors.New("cancelled by user")
+
+// errSupersededByForce is the cancellation cause recorded when a forced wake replaces an operation
+var errSupersededByForce = errors.New("superseded by a forced wake")
+
+// operationGenKey carries the generation of the operation a context belongs to
+type operationGenKey struct{}
+
+// beginOperationLocked creates the context for a new wake or power-off; the caller must hold wakeMutex
+func (w *WOLPlugin) beginOperationLocked() context.Context {
+	ctx, cancel := context.WithCancelCause(w.ctx)
+	w.operationCancel = cancel
+	w.operationGen++
+	return context.WithValue(ctx, operationGenKey{}, w.operationGen)
+}
+
+// isCurrentOperationLocked reports whether ctx belongs to the operation wakeCache describes, i.e. it was not
+// superseded by a forced wake. Contexts not created by beginOperationLocked always count as current.
+// The caller must hold wakeMutex.
+func (w *WOLPlugin) isCurrentOperationLocked(ctx context.Context) bool {
+	gen, ok := ctx.Value(operationGenKey{}).(uint64)
+	return !ok || gen == w.operationGen
+}
+
+// forceResetOperation abandons the running wake or power-off, even one that is stuck, so that a new wake can
+// start. The old sequence is cancelled and whatever it still reports is discarded. The last failure is kept.
+// Its operation slot is freed right away, since the sequence may take a while to notice, e.g. while a health
+// probe runs to its timeout.
+func (w *WOLPlugin) forceResetOperation() {
+	w.wakeMutex.Lock()
+	defer w.wakeMutex.Unlock()
+
+	if w.operationCancel != nil {
+		w.operationCancel(errSupersededByForce)
+		w.operationCancel = nil
+	}
+	if w.operationSlot != nil {
+		w.operationSlot.release()
+		w.operationSlot = nil
+	}
+	if w.wakeCache.isWaking {
+		// The abandoned sequence may hold the circuit breaker's half-open trial
+		w.abandonWakeAttempt()
+	}
+	w.operationGen++
+	w.draining = false
+	w.wakeCache = &wakeStatus{
+		lastError:       w.wakeCache.lastError,
+		lastFailureTime: w.wakeCache.lastFailureTime,
+	}
+	w.notifyWakeChangeLocked()
+}
+
+// endOperationLocked releases the running operation's context; the caller must hold wakeMutex
+func (w *WOLPlugin) endOperationLocked() {
+	if w.operationCancel != nil {
+		w.operationCancel(nil)
+		w.operationCancel = nil
+	}
+}
+
+// cancelOperation aborts the running wake or power-off sequence
+func (w *WOLPlugin) cancelOperation() error {
+	w.wakeMutex.Lock()
+	defer w.wakeMutex.Unlock()
+
+	if w.operationCancel == nil {
+		return &operationError{
+			code:    codeNotRunning,
+			status:  http.StatusConflict,
+			message: "no wake or power-off process in progress",
+		}
+	}
+	w.operationCancel(errCancelledByUser)
+	return nil
+}
+
+// handleCancelEndpoint handles POST requests to /_wol/cancel
+func (w *WOLPlugin) handleCancelEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !w.validCSRFToken(req) {
+		w.writeOperationError(rw, errCSRFInvalid)
+		return
+	}
+
+	if err := w.cancelOperation(); err != nil {
+		w.writeOperationError(rw, err)
+		return
+	}
+
+	fmt.Printf("WOL Plugin [%s]: Cancellation requested by user\n", w.name)
+	w.writeJSONResponse(rw, map[string]interface{}{
+		"success": true,
+		"message": "Cancellation requested",
+	})
+}
+
+// handleStatusEndpoint handles GET requests to /_wol/status
+func (w *WOLPlugin) handleStatusEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.writeJSONResponse(rw, w.statusResponse())
+}
+
+// handleHealthEndpoint handles GET requests to /_wol/health, reporting the cached health view
+// without probing the service unless ?fresh=true is given
+func (w *WOLPlugin) handleHealthEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if fresh, _ := strconv.ParseBool(req.URL.Query().Get("fresh")); fresh {
+		w.refreshHealthStatus()
+	}
+
+	w.healthMutex.RLock()
+	cache := *w.healthCache
+	w.healthMutex.RUnlock()
+
+	var lastCheck interface{}
+	var lastCheckAge interface{}
+	if !cache.lastCheck.IsZero() {
+		lastCheck = cache.lastCheck.UTC().Format(time.RFC3339)
+		lastCheckAge = w.now().Sub(cache.lastCheck).Seconds()
+	}
+
+	w.activityMutex.RLock()
+	quietRequests := w.quietRequests
+	w.activityMutex.RUnlock()
+
+	w.writeJSONResponse(rw, map[string]interface{}{
+		"isHealthy":           cache.isHealthy,
+		"lastCheck":           lastCheck,
+		"lastCheckAgeSeconds": lastCheckAge,
+		"healthCheckInterval": w.healthCheckInterval.Seconds(),
+		"quietRequests":       quietRequests,
+	})
+}
+
+// handleVersionEndpoint handles GET requests to /_wol/version, reporting the plugin version and
+// which features this route has enabled
+func (w *WOLPlugin) handleVersionEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.writeJSONResponse(rw, map[string]interface{}{
+		"version":            PluginVersion,
+		"name":               w.name,
+		"serviceDescription": w.renderPageText(w.serviceDescriptionTmpl, w.serviceDescription, req),
+		"features": map[string]interface{}{
+			"controlPage":     w.enableControlPage,
+			"powerOffMethod":  w.powerOffMethod,
+			"healthCheckType": w.healthCheckType,
+			"healthCheckMode": w.healthCheckMode,
+			"autoWakeMode":    w.autoWakeMode,
+			"dryRun":          w.dryRun,
+			"maintenanceMode": w.maintenanceMode,
+		},
+	})
+}
+
+// handleDiagnosticsEndpoint handles GET requests to /_wol/diagnostics, reporting how the plugin reads its
+// configuration: the parsed MAC, the broadcast addresses it would send to, interface discovery errors and a
+// live health probe. Nothing is sent and the health cache is left alone. It requires adminToken when one is
+// configured, and is otherwise only served with debug enabled.
+func (w *WOLPlugin) handleDiagnosticsEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if !w.authorizeDebugEndpoint(rw, req) {
+		return
+	}
+
+	mac := map[string]interface{}{"configured": w.macAddress}
+	if macBytes, err := w.parseMACAddress(w.macAddress); err != nil {
+		mac["error"] = err.Error()
+	} else {
+		parsed := make([]int, len(macBytes))
+		for i, b := range macBytes {
+			parsed[i] = int(b)
+		}
+		mac["bytes"] = parsed
+		mac["normalized"] = net.HardwareAddr(macBytes).String()
+	}
+
+	interfaceNames := []string{}
+	interfaceErrors := []string{}
+	if interfaces, err := w.getNetworkInterfaces(); err != nil {
+		interfaceErrors = append(interfaceErrors, err.Error())
+	} else {
+		for _, iface := range interfaces {
+			interfaceNames = append(interfaceNames, iface.Name)
+			if _, err := w.interfaces.Addrs(iface); err != nil {
+				interfaceErrors = append(interfaceErrors, fmt.Sprintf("%s: %v", iface.Name, err))
+			}
+		}
+	}
+
+	targets := []map[string]interface{}{}
+	for _, target := range w.wakeTargets() {
+		targets = append(targets, map[string]interface{}{
+			"kind":     target.kind,
+			"address":  target.address,
+			"endpoint": w.targetEndpoint(target.address),
+		})
+	}
+
+	broadcastAddresses := w.getBroadcastAddresses()
+	if broadcastAddresses == nil {
+		broadcastAddresses = []string{}
+	}
+
+	start := w.now()
+	healthy := w.performHealthCheck()
+	latency := w.now().Sub(start)
+
+	w.writeJSONResponse(rw, map[string]interface{}{
+		"name":               w.name,
+		"macAddress":         mac,
+		"broadcastAddresses": broadcastAddresses,
+		"wakeTargets":        targets,
+		"interfaces":         interfaceNames,
+		"interfaceErrors":    interfaceErrors,
+		"health": map[string]interface{}{
+			"type":      w.healthCheckType,
+			"isHealthy": healthy,
+			"latencyMs": latency.Milliseconds(),
+		},
+	})
+}
+
+// authorizeDebugEndpoint gates the GET-only debugging endpoints: hidden unless debug or adminToken is set, and
+// requiring the admin token when one is configured. It writes the rejection itself and reports whether to continue.
+func (w *WOLPlugin) authorizeDebugEndpoint(rw http.ResponseWriter, req *http.Request) bool {
+	if w.adminToken == "" && !w.debug {
+		http.NotFound(rw, req)
+		return false
+	}
+	if req.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if w.adminToken != "" && !w.validAdminToken(req) {
+		rw.Header().Set("WWW-Authenticate", `Bearer realm="wol-admin"`)
+		w.writeOperationError(rw, &operationError{
+			code:    codeUnauthorized,
+			status:  http.StatusUnauthorized,
+			message: "missing or invalid admin token",
+		})
+		return false
+	}
+	return true
+}
+
+// redactedValue replaces secrets in /_wol/config
+const redactedValue = "[redacted]"
+
+// handleConfigEndpoint handles GET requests to /_wol/config with the settings New resolved, defaults applied
+// and durations parsed, keyed by their configuration names. Tokens, header values and URL passwords are
+// redacted, and key material is only reported as configured or not. It is gated like /_wol/diagnostics.
+func (w *WOLPlugin) handleConfigEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if !w.authorizeDebugEndpoint(rw, req) {
+		return
+	}
+	w.writeJSONResponse(rw, w.configSnapshot())
+}
+
+// configSnapshot returns the resolved settings served by /_wol/config
+func (w *WOLPlugin) configSnapshot() map[string]interface{} {
+	healthChecks := make([]string, len(w.healthChecks))
+	for i, healthCheck := range w.healthChecks {
+		healthChecks[i] = redactURL(healthCheck)
+	}
+	healthCheckHeaders := make(map[string]string, len(w.healthCheckHeaders))
+	for name := range w.healthCheckHeaders {
+		healthCheckHeaders[name] = redactedValue
+	}
+	gatewayHeaders := make(map[string]string, len(w.gatewayHeaders))
+	for name := range w.gatewayHeaders {
+		gatewayHeaders[name] = redactedValue
+	}
+	wakeOnMethods := []string{}
+	for method := range w.wakeOnMethods {
+		wakeOnMethods = append(wakeOnMethods, method)
+	}
+	sort.Strings(wakeOnMethods)
+	healthCheckProxyURL := ""
+	if w.healthCheckProxyURL != nil {
+		healthCheckProxyURL = w.healthCheckProxyURL.Redacted()
+	}
+	redactIfSet := func(value string) string {
+		if value == "" {
+			return ""
+		}
+		return redactedValue
+	}
+	subnets := func(networks []*net.IPNet) []string {
+		values := []string{}
+		for _, network := range networks {
+			values = append(values, network.String())
+		}
+		return values
+	}
+	schedule := []string{}
+	for _, window := range w.schedule {
+		schedule = append(schedule, window.String())
+	}
+	scheduleTimezone := ""
+	if w.scheduleLocation != nil {
+		scheduleTimezone = w.scheduleLocation.String()
+	}
+	var healthCheckExpectBodyRegex string
+	if w.healthCheckExpectBodyRegex != nil {
+		healthCheckExpectBodyRegex = w.healthCheckExpectBodyRegex.String()
+	}
+	var sshUser string
+	var sshKey, sshHostKey bool
+	if runner, ok := w.sshRunner.(*sshRunner); ok {
+		sshUser, sshKey, sshHostKey = runner.user, runner.signer != nil, len(runner.hostKey) > 0
+	}
+	var mqttBroker, mqttClientID, mqttUsername, mqttPassword string
+	if client, ok := w.publisher.(*mqttClient); ok {
+		mqttBroker, mqttClientID, mqttUsername, mqttPassword = client.address, client.clientID, client.username, redactIfSet(client.password)
+	}
+	var tracingEndpoint string
+	if exporter, ok := w.spanExporter.(*otlpExporter); ok {
+		tracingEndpoint = redactURL(exporter.endpoint)
+	}
+
+	return map[string]interface{}{
+		"name":                        w.name,
+		"healthChecks":                healthChecks,
+		"healthCheckMode":             w.healthCheckMode,
+		"healthCheckType":             w.healthCheckType,
+		"healthCheckInterval":         w.healthCheckInterval.String(),
+		"healthCheckJitter":           w.healthCheckJitter.String(),
+		"healthFlapThreshold":         w.healthFlapThreshold,
+		"statusMaxStaleness":          w.statusMaxStaleness.String(),
+		"startupGracePeriod":          w.startupGracePeriod.String(),
+		"warmHealthCacheOnStart":      w.warmHealthCacheOnStart,
+		"healthCheckMethod":           w.healthCheckMethod,
+		"healthCheckBody":             w.healthCheckBody,
+		"healthCheckHeaders":          healthCheckHeaders,
+		"forwardHealthAuthFromRequest": len(w.forwardHealthAuthHeaders) > 0,
+		"forwardHealthAuthHeaders":    w.forwardHealthAuthHeaders,
+		"healthCheckFollowRedirects":  w.healthCheckFollowRedirects,
+		"healthCheckMaxRedirects":     w.healthCheckMaxRedirects,
+		"healthCheckClientCert":       w.healthCheckClientCert != nil,
+		"healthCheckClientKey":        w.healthCheckClientCert != nil,
+		"healthCheckGrpcService":      w.healthCheckGRPCService,
+		"healthCheckUserAgent":        w.healthCheckUserAgent,
+		"healthCheckDisableKeepAlive": w.healthCheckDisableKeepAlive,
+		"healthCheckMaxLatency":       w.healthCheckMaxLatency.String(),
+		"healthCheckExpectBody":       w.healthCheckExpectBody,
+		"healthCheckExpectBodyRegex":  healthCheckExpectBodyRegex,
+		"maxHealthBodyBytes":          w.maxHealthBodyBytes,
+		"healthCheckProxyUrl":         healthCheckProxyURL,
+		"readinessCheck":              redactURL(w.readinessCheck),
+		"macAddress":                  w.macAddress,
+		"ipAddress":                   w.ipAddress,
+		"wakeTargetResolveTTL":        w.wakeTargetResolveTTL.String(),
+		"broadcastAddresses":          w.broadcastAddresses,
+		"allowedSubnets":              subnets(w.allowedSubnets),
+		"networkInterface":            w.networkInterface,
+		"wakeTransport":               w.wakeTransport,
+		"wakeMethod":                  w.wakeMethod,
+		"gatewayURL":                  redactURL(w.gatewayURL),
+		"gatewayToken":                redactIfSet(w.gatewayToken),
+		"gatewayHeaders":              gatewayHeaders,
+		"gatewayBody":                 w.gatewayBody,
+		"gatewayTimeout":              w.gatewayTimeout.String(),
+		"enableIPv6":                  w.enableIPv6,
+		"wakeTargetOrder":             w.wakeTargetOrder,
+		"stopOnFirstSuccess":          w.stopOnFirstSuccess,
+		"parallelWakeSends":           w.parallelWakeSends,
+		"reuseWakeConnections":        w.reuseWakeConnections,
+		"port":                        w.port,
+		"sourcePort":                  w.sourcePort,
+		"packetRepeat":                w.packetRepeat,
+		"packetRepeatDelay":           w.packetRepeatDelay.String(),
+		"packetFormat":                w.packetFormat,
+		"secureOnPassword":            redactIfSet(string(w.secureOnPassword)),
+		"timeout":                     w.timeout.String(),
+		"retryAttempts":               w.retryAttempts,
+		"retryInterval":               w.retryInterval.String(),
+		"retryBackoff":                w.retryBackoff,
+		"retryMaxInterval":            w.retryMaxInterval.String(),
+		"wakePollInterval":            w.wakePollInterval.String(),
+		"wakePollMaxInterval":         w.wakePollMaxInterval.String(),
+		"wakeHealthyThreshold":        w.wakeHealthyThreshold,
+		"progressSendPct":             w.progressSendPct,
+		"progressWaitPct":             w.progressWaitPct,
+		"maxConcurrentOperations":     cap(w.operationSlots),
+		"autoWakeMode":                w.autoWakeMode,
+		"autoWakeRetryAfter":          w.autoWakeRetryAfter.String(),
+		"maxClientWait":               w.maxClientWait.String(),
+		"coldResponseBody":            w.coldResponseBody != "",
+		"coldResponseContentType":     w.coldResponseContentType,
+		"wakeOnMethods":               wakeOnMethods,
+		"nonWakeMethodStatus":         w.nonWakeMethodStatus,
+		"fallbackURL":                 w.fallbackProxy != nil,
+		"preWakeWebhookURL":           redactURL(w.preWakeWebhookURL),
+		"preWakeWebhookTimeout":       w.preWakeWebhookTimeout.String(),
+		"preWakeWebhookAttempts":      w.preWakeWebhookAttempts,
+		"circuitBreakerThreshold":     w.circuitBreakerThreshold,
+		"circuitBreakerWindow":        w.circuitBreakerWindow.String(),
+		"circuitBreakerCooldown":      w.circuitBreakerCooldown.String(),
+		"debug":                       w.debug,
+		"quietWhenHealthy":            w.quietWhenHealthy,
+		"dryRun":                      w.dryRun,
+		"maintenanceMode":             w.maintenanceMode,
+		"maintenanceMessage":          w.maintenanceMessage,
+		"enableControlPage":           w.enableControlPage,
+		"controlPageTitle":            w.controlPageTitle,
+		"serviceDescription":          w.serviceDescription,
+		"controlPageTemplatePath":     w.controlPageTemplatePath,
+		"controlPageTemplateInline":   w.controlPageTemplateInline,
+		"controlPageCustomCSS":        w.controlPageCustomCSS != "",
+		"controlPageLogoURL":          w.controlPageLogoURL,
+		"controlPageSecurityHeaders":  w.controlPageSecurityHeaders,
+		"controlPageCSP":              w.controlPageCSP,
+		"language":                    w.language,
+		"statusPollIntervalMs":        w.statusPollIntervalMs,
+		"statusPollMaxIntervalMs":     w.statusPollMaxIntervalMs,
+		"statusPollBackoff":           w.statusPollBackoff,
+		"autoRedirect":                w.autoRedirect,
+		"redirectDelay":               w.redirectDelay.String(),
+		"autoRedirectRequireStableHealthy": w.autoRedirectStablePolls,
+		"redirectTarget":              w.redirectTarget,
+		"redirectStatusCode":          w.redirectStatusCode,
+		"bypassSessionDuration":       w.bypassSessionDuration.String(),
+		"skipControlPageWhenHealthy":  w.skipControlPageWhenHealthy,
+		"contentNegotiation":          w.contentNegotiation,
+		"showPowerOffButton":          w.showPowerOffButton,
+		"confirmPowerOff":             w.confirmPowerOff,
+		"powerOffConfirmMessage":      w.powerOffConfirmMessage,
+		"powerOffRequireTyping":       w.powerOffRequireTyping,
+		"hideRedirectButton":          w.hideRedirectButton,
+		"showTargetDetails":           w.showTargetDetails,
+		"enableCSRFProtection":        w.enableCSRFProtection,
+		"allowedControlIPs":           subnets(w.allowedControlIPs),
+		"readOnlyControlIPs":          subnets(w.readOnlyControlIPs),
+		"controlRoleHeader":           w.controlRoleHeader,
+		"readOnlyRoles":               w.readOnlyRoles,
+		"trustForwardedFor":           w.trustForwardedFor,
+		"adminToken":                  redactIfSet(w.adminToken),
+		"powerOffMethod":              w.powerOffMethod,
+		"powerOffCommand":             redactIfSet(w.powerOffCommand),
+		"sshHost":                     w.sshHost,
+		"sshUser":                     sshUser,
+		"sshKey":                      sshKey,
+		"sshHostKey":                  sshHostKey,
+		"sshCommand":                  redactIfSet(w.sshCommand),
+		"powerOffDrainPeriod":         w.powerOffDrainPeriod.String(),
+		"powerOffTimeout":             w.powerOffTimeout.String(),
+		"shutdownCheck":               redactURL(w.shutdownCheck),
+		"idleShutdownTimeout":         w.idleShutdownTimeout.String(),
+		"keepAliveInterval":           w.keepAliveInterval.String(),
+		"keepAliveURL":                redactURL(w.keepAliveURL),
+		"keepAliveIdleTimeout":        w.keepAliveIdleTimeout.String(),
+		"schedule":                    schedule,
+		"scheduleTimezone":            scheduleTimezone,
+		"mqttBroker":                  mqttBroker,
+		"mqttClientId":                mqttClientID,
+		"mqttUsername":                mqttUsername,
+		"mqttPassword":                mqttPassword,
+		"mqttTopic":                   w.mqttTopic,
+		"auditLogPath":                w.auditLogPath,
+		"enableTracing":               w.enableTracing,
+		"tracingEndpoint":             tracingEndpoint,
+	}
+}
+
+// redactURL hides the password in a URL's userinfo; values that do not parse are returned unchanged
+func redactURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	return parsed.Redacted()
+}
+
+// statusResponse builds the status payload shared by the polling and streaming endpoints
+func (w *WOLPlugin) statusResponse() map[string]interface{} {
+	isHealthy := w.statusHealthStatus()
+	w.healthMutex.RLock()
+	healthyProbes := w.healthCache.healthyProbes
+	w.healthMutex.RUnlock()
+	
+	w.wakeMutex.RLock()
+	wakeStatus := *w.wakeCache
+	w.wakeMutex.RUnlock()
+
+	// Timing is only meaningful while an operation is running
+	elapsed, eta := 0, 0
+	if wakeStatus.isWaking || wakeStatus.isPoweringOff {
+		elapsedTime := w.now().Sub(wakeStatus.startTime)
+		elapsed = int(elapsedTime.Seconds())
+		if remaining := w.timeout - elapsedTime; remaining > 0 {
+			eta = int(remaining.Seconds())
+		}
+	}
+
+	var lastFailureTime interface{}
+	if !wakeStatus.lastFailureTime.IsZero() {
+		lastFailureTime = wakeStatus.lastFailureTime.UTC().Format(time.RFC3339)
+	}
+
+	return map[string]interface{}{
+		"isHealthy":       isHealthy,
+		"healthyProbes":   healthyProbes,
+		"isWaking":        wakeStatus.isWaking,
+		"isPoweringOff":   wakeStatus.isPoweringOff,
+		"message":         wakeStatus.message,
+		"progress":        wakeStatus.progress,
+		"elapsedSeconds":  elapsed,
+		"etaSeconds":      eta,
+		"lastError":       wakeStatus.lastError,
+		"lastFailureTime": lastFailureTime,
+		"dialFailure":     w.lastDialFailure(),
+		"healthLatencyMs": w.lastHealthLatency().Milliseconds(),
+		"maintenanceMode": w.maintenanceMode,
+		"isDraining":      w.isDraining(),
+	}
+}
+
+// notifyWakeChangeLocked wakes up status stream subscribers; the caller must hold wakeMutex for writing
+func (w *WOLPlugin) notifyWakeChangeLocked() {
+	if w.wakeChanged != nil {
+		close(w.wakeChanged)
+	}
+	w.wakeChanged = make(chan struct{})
+}
+
+// handleEventsEndpoint handles GET requests to /_wol/events, streaming status as Server-Sent Events
+func (w *WOLPlugin) handleEventsEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	lastSent := ""
+	for {
+		// Grab the change channel before reading status so no update is missed in between
+		w.wakeMutex.RLock()
+		changed := w.wakeChanged
+		w.wakeMutex.RUnlock()
+
+		payload, err := json.Marshal(w.statusResponse())
+		if err != nil {
+			return
+		}
+		if string(payload) != lastSent {
+			if _, err := fmt.Fprintf(rw, "data: %s\n\n", payload); err != nil {
+				return
+			}
+			flusher.Flush()
+			lastSent = string(payload)
+		}
+
+		select {
+		case <-req.Context().Done():
+			return
+		case <-changed:
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(rw, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+
+// WebSocket framing per RFC 6455, implemented here because Yaegi cannot load third-party websocket packages
+const (
+	wsAcceptGUID      = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsOpText          = 0x1
+	wsOpClose         = 0x8
+	wsOpPing          = 0x9
+	wsOpPong          = 0xA
+	wsMaxFramePayload = 64 << 10
+	wsWriteTimeout    = 10 * time.Second
+)
+
+// Close codes sent by the status WebSocket
+const (
+	wsCloseNormal        = 1000
+	wsCloseGoingAway     = 1001
+	wsCloseProtocolError = 1002
+	wsCloseTooBig        = 1009
+)
+
+// wsConn is a server-side WebSocket connection; writes may come from the status loop and the read loop
+type wsConn struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	writeMutex sync.Mutex
+}
+
+// writeFrame sends a single unmasked, unfragmented frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeClose sends a close frame with the given status code and reason
+func (c *wsConn) writeClose(code int, reason string) error {
+	payload := []byte{byte(code >> 8), byte(code)}
+	return c.writeFrame(wsOpClose, append(payload, reason...))
+}
+
+// readFrame reads one frame from the client, which must be masked
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return 0, nil, &wsError{code: wsCloseProtocolError, reason: "client frames must be masked"}
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxFramePayload {
+		return 0, nil, &wsError{code: wsCloseTooBig, reason: "frame too large"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// wsError is a protocol violation by the client, answered with a close frame
+type wsError struct {
+	code   int
+	reason string
+}
+
+func (e *wsError) Error() string {
+	return e.reason
+}
+
+// readLoop answers pings and close frames and discards client data until the connection ends, then closes done
+func (c *wsConn) readLoop(done chan<- struct{}) {
+	defer close(done)
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			var protocolErr *wsError
+			if errors.As(err, &protocolErr) {
+				c.writeClose(protocolErr.code, protocolErr.reason)
+			}
+			return
+		}
+		switch opcode {
+		case wsOpPing:
+			if c.writeFrame(wsOpPong, payload) != nil {
+				return
+			}
+		case wsOpClose:
+			// Echo the client's status code to complete the closing handshake
+			if len(payload) >= 2 {
+				c.writeFrame(wsOpClose, payload[:2])
+			} else {
+				c.writeFrame(wsOpClose, nil)
+			}
+			return
+		}
+	}
+}
+
+// headerHasToken reports whether a comma-separated header such as Connection lists token
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleWebSocketEndpoint handles GET requests to /_wol/ws, pushing the status JSON as WebSocket text frames
+// whenever it changes. The connection is closed once a running operation completes or the client goes away.
+func (w *WOLPlugin) handleWebSocketEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !headerHasToken(req.Header, "Connection", "upgrade") || !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		http.Error(rw, "WebSocket upgrade required", http.StatusBadRequest)
+		return
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		rw.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(rw, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(rw, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		http.Error(rw, "WebSocket unsupported", http.StatusInternalServerError)
+		return
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(rw, "WebSocket unsupported", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	accept := sha1.Sum([]byte(key + wsAcceptGUID))
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(accept[:])); err != nil {
+		return
+	}
+
+	ws := &wsConn{conn: conn, reader: buffered.Reader}
+	clientDone := make(chan struct{})
+	go ws.readLoop(clientDone)
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	lastSent := ""
+	operationSeen := false
+	for {
+		// Grab the change channel before reading status so no update is missed in between
+		w.wakeMutex.RLock()
+		changed := w.wakeChanged
+		w.wakeMutex.RUnlock()
+
+		status := w.statusResponse()
+		payload, err := json.Marshal(status)
+		if err != nil {
+			return
+		}
+		if string(payload) != lastSent {
+			if ws.writeFrame(wsOpText, payload) != nil {
+				return
+			}
+			lastSent = string(payload)
+		}
+
+		running := status["isWaking"] == true || status["isPoweringOff"] == true
+		if running {
+			operationSeen = true
+		} else if operationSeen {
+			ws.writeClose(wsCloseNormal, "operation complete")
+			return
+		}
+
+		select {
+		case <-clientDone:
+			return
+		case <-w.ctx.Done():
+			ws.writeClose(wsCloseGoingAway, "plugin shutting down")
+			return
+		case <-changed:
+		case <-keepAlive.C:
+			if ws.writeFrame(wsOpPing, nil) != nil {
+				return
+			}
+		}
+	}
+}
+
+// performAutoWake handles the legacy auto-wake behavior when control page is disabled
+func (w *WOLPlugin) performAutoWake(rw http.ResponseWriter, req *http.Request) {
+	if w.autoWakeMode == autoWakeModeAsync {
+		w.performAsyncAutoWake(rw, req)
+		return
+	}
+
+	span := w.startSpan("wol.auto_wake", req)
+	span.setAttribute("wol.mac", w.macAddress)
+	span.setAttribute("http.method", req.Method)
+	span.setAttribute("url.path", req.URL.Path)
+
+	// The first cold request starts the wake; concurrent ones wait for it instead of sending packets of their own
+	w.autoWakeFlightMutex.Lock()
+	call := w.autoWakeFlight
+	leader := call == nil
+	if leader {
+		call = &autoWakeCall{done: make(chan struct{})}
+		w.autoWakeFlight = call
+	}
+	w.autoWakeFlightMutex.Unlock()
+
+	if leader {
+		// The wake runs on its own so it carries on when the client gives up or maxClientWait runs out
+		forwarded := w.forwardedHealthHeaders(req)
+		go func() {
+			call.result = w.runAutoWake(span, forwarded)
+			span.finish()
+			w.autoWakeFlightMutex.Lock()
+			w.autoWakeFlight = nil
+			w.autoWakeFlightMutex.Unlock()
+			close(call.done)
+		}()
+	} else {
+		defer span.finish()
+		span.setAttribute("wol.coalesced", true)
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Waiting for the wake started by another request\n", w.name)
+		}
+	}
+
+	var timeout <-chan time.Time
+	if w.maxClientWait > 0 {
+		timer := time.NewTimer(w.maxClientWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-call.done:
+	case <-timeout:
+		w.writeStillWaking(rw, span, leader)
+		return
+	case <-req.Context().Done():
+		// A request deadline gets the same answer as maxClientWait; a client that went away gets none
+		if errors.Is(req.Context().Err(), context.DeadlineExceeded) {
+			w.writeStillWaking(rw, span, leader)
+			return
+		}
+		if !leader {
+			span.setError("client went away while waiting for the wake")
+		}
+		return
+	}
+	if !leader && !call.result.success {
+		span.setError(call.result.message)
+	}
+
+	if !call.result.success {
+		if call.result.retryAfter > 0 {
+			rw.Header().Set("Retry-After", retryAfterSeconds(call.result.retryAfter))
+		}
+		if w.coldResponseBody != "" {
+			w.writeColdResponse(rw)
+			return
+		}
+		http.Error(rw, call.result.message, http.StatusServiceUnavailable)
+		return
+	}
+
+	// Let the service continue the trace under the wake span
+	span.inject(req.Header)
+	w.serveNext(rw, req)
+}
+
+// writeStillWaking releases a client whose wait for a blocking auto-wake ran out with a 503 and
+// Retry-After, while the wake itself continues
+func (w *WOLPlugin) writeStillWaking(rw http.ResponseWriter, span *traceSpan, leader bool) {
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: Releasing client before the service came up; the wake continues in the background\n", w.name)
+	}
+	if !leader {
+		span.setError("client wait limit reached before the service came up")
+	}
+	rw.Header().Set("Retry-After", retryAfterSeconds(w.autoWakeRetryAfter))
+	if w.coldResponseBody != "" {
+		w.writeColdResponse(rw)
+		return
+	}
+	http.Error(rw, "Service is still waking up, please retry shortly", http.StatusServiceUnavailable)
+}
+
+// autoWakeCall is a blocking auto-wake in flight whose result is shared by every cold request waiting on it
+type autoWakeCall struct {
+	done   chan struct{}
+	result autoWakeResult
+}
+
+// autoWakeResult is the outcome of a blocking auto-wake; message and retryAfter describe a failure to the client
+type autoWakeResult struct {
+	success    bool
+	message    string
+	retryAfter time.Duration
+}
+
+// runAutoWake sends magic packets and waits for the service, recording the attempts under span
+func (w *WOLPlugin) runAutoWake(span *traceSpan, forwarded http.Header) (result autoWakeResult) {
+	attempts := 0
+	var started time.Time
+	defer func() {
+		w.closeWakeConnections()
+		span.setAttribute("wol.attempts", attempts)
+		if started.IsZero() {
+			return
+		}
+		event := WakeEvent{Attempts: attempts, Duration: w.now().Sub(started), Message: result.message}
+		if result.success || w.dryRun {
+			w.runWakeHook(w.currentWakeHooks().OnWakeSuccess, event)
+		} else {
+			w.runWakeHook(w.currentWakeHooks().OnWakeFailure, event)
+		}
+	}()
+
+	if retryAfter, ok := w.allowWakeAttempt(); !ok {
+		span.setError("wake attempts suspended by circuit breaker")
+		return autoWakeResult{
+			message:    "Service is unavailable and wake attempts are suspended after repeated failures",
+			retryAfter: retryAfter,
+		}
+	}
+
+	started = w.now()
+	w.runWakeHook(w.currentWakeHooks().OnWakeStart, WakeEvent{})
+
+	fmt.Printf("WOL Plugin [%s]: Service unhealthy, attempting to wake %s\n", w.name, w.macAddress)
+
+	if err := w.callPreWakeWebhook(w.ctx, span); err != nil {
+		fmt.Printf("WOL Plugin [%s]: %v\n", w.name, err)
+		w.recordWakeResult(w.ctx, false)
+		span.setError(err.Error())
+		return autoWakeResult{message: "Service is unavailable and could not be powered on before waking"}
+	}
+	
+	success := false
+	for attempt := 1; attempt <= w.retryAttempts; attempt++ {
+		attempts = attempt
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Wake attempt %d/%d\n", w.name, attempt, w.retryAttempts)
+		}
+
+		if err := w.sendWOLPacketTraced(span, attempt); err != nil {
+			fmt.Printf("WOL Plugin [%s]: Failed to send WOL packet (attempt %d): %v\n", w.name, attempt, err)
+			if attempt < w.retryAttempts && w.sleep(w.ctx, w.retryDelay(attempt)) {
+				continue
+			}
+			w.recordWakeResult(w.ctx, false)
+			span.setError(err.Error())
+			return autoWakeResult{message: "Failed to wake up service after all attempts"}
+		}
+
+		if w.dryRun {
+			w.abandonWakeAttempt()
+			return autoWakeResult{message: "Dry run: service would be woken, no WOL packet was sent"}
+		}
+
+		waitSpan := span.startChild("wol.wait_for_service")
+		waitSpan.setAttribute("wol.attempt", attempt)
+		healthy := w.waitForService(forwarded)
+		if !healthy {
+			waitSpan.setError("service did not become healthy before the timeout")
+		}
+		waitSpan.finish()
+		if healthy {
+			success = true
+			break
+		}
+
+		if attempt < w.retryAttempts {
+			delay := w.retryDelay(attempt)
+			fmt.Printf("WOL Plugin [%s]: Service not responding, retrying in %v\n", w.name, delay)
+			if !w.sleep(w.ctx, delay) {
+				break
+			}
+		}
+	}
+
+	w.recordWakeResult(w.ctx, success)
+	if !success {
+		fmt.Printf("WOL Plugin [%s]: Service did not come online after %d attempts\n", w.name, w.retryAttempts)
+		span.setError("service did not respond after wake attempts")
+		return autoWakeResult{message: "Service did not respond after wake up attempts"}
+	}
+
+	fmt.Printf("WOL Plugin [%s]: Service is now online\n", w.name)
+	return autoWakeResult{success: true}
+}
+
+const (
+	// defaultPreWakeWebhookTimeout bounds each call to preWakeWebhookURL
+	defaultPreWakeWebhookTimeout = 10 * time.Second
+	// defaultPreWakeWebhookAttempts is how often preWakeWebhookURL is tried before the wake is aborted
+	defaultPreWakeWebhookAttempts = 3
+)
+
+// callPreWakeWebhook POSTs to preWakeWebhookURL until it answers with a 2xx status, retrying with the wake
+// retry delays. It returns nil without a configured webhook, and in dry runs, where it only logs the call.
+func (w *WOLPlugin) callPreWakeWebhook(ctx context.Context, parent *traceSpan) error {
+	if w.preWakeWebhookURL == "" {
+		return nil
+	}
+	if w.dryRun {
+		fmt.Printf("WOL Plugin [%s]: Dry run - would call pre-wake webhook %s\n", w.name, w.preWakeWebhookURL)
+		return nil
+	}
+
+	span := parent.startChild("wol.pre_wake_webhook")
+	defer span.finish()
+
+	var err error
+	for attempt := 1; attempt <= w.preWakeWebhookAttempts; attempt++ {
+		span.setAttribute("wol.attempts", attempt)
+		if err = w.postPreWakeWebhook(ctx); err == nil {
+			if w.debug {
+				fmt.Printf("WOL Plugin [%s]: Pre-wake webhook succeeded (attempt %d)\n", w.name, attempt)
+			}
+			return nil
+		}
+		fmt.Printf("WOL Plugin [%s]: Pre-wake webhook failed (attempt %d/%d): %v\n", w.name, attempt, w.preWakeWebhookAttempts, err)
+		if attempt < w.preWakeWebhookAttempts && !w.sleep(ctx, w.retryDelay(attempt)) {
+			break
+		}
+	}
+	err = fmt.Errorf("pre-wake webhook failed after %d attempts: %v", w.preWakeWebhookAttempts, err)
+	span.setError(err.Error())
+	return err
+}
+
+// postPreWakeWebhook makes a single call to preWakeWebhookURL, bounded by preWakeWebhookTimeout
+func (w *WOLPlugin) postPreWakeWebhook(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, w.preWakeWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.preWakeWebhookURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+
+	resp, err := w.preWakeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// newFallbackProxy builds the reverse proxy that serves cold requests from fallbackURL
+func (w *WOLPlugin) newFallbackProxy(target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, err error) {
+		fmt.Printf("WOL Plugin [%s]: Fallback request to %s failed: %v\n", w.name, target.Host, err)
+		http.Error(rw, "Service is starting up and the fallback is unavailable", http.StatusBadGateway)
+	}
+	return proxy
+}
+
+// serveFallback starts a background wake unless one is already running and proxies the request to fallbackURL
+func (w *WOLPlugin) serveFallback(rw http.ResponseWriter, req *http.Request) {
+	err := w.startWake(w.startSpan("wol.auto_wake", req), w.forwardedHealthHeaders(req))
+	var opErr *operationError
+	switch {
+	case err == nil:
+		fmt.Printf("WOL Plugin [%s]: Service unhealthy, serving the fallback while waking %s\n", w.name, w.macAddress)
+	case errors.As(err, &opErr) && opErr.code == codeAlreadyRunning:
+		// An earlier request already started the wake
+	default:
+		fmt.Printf("WOL Plugin [%s]: Could not start wake while serving the fallback: %v\n", w.name, err)
+	}
+	w.fallbackProxy.ServeHTTP(rw, req)
+}
+
+// Auto-wake modes selecting whether a cold request waits for the service
+const (
+	autoWakeModeBlocking = "blocking"
+	autoWakeModeAsync    = "async"
+)
+
+// defaultAutoWakeRetryAfter is the Retry-After sent with async auto-wake responses
+const defaultAutoWakeRetryAfter = 5 * time.Second
+
+// performAsyncAutoWake starts the wake in the background and answers immediately with 503 and Retry-After,
+// so clients retry instead of holding the connection open for the whole wake timeout
+func (w *WOLPlugin) performAsyncAutoWake(rw http.ResponseWriter, req *http.Request) {
+	state := "waking"
+	message := "Service is waking up, please retry shortly"
+	retryAfter := w.autoWakeRetryAfter
+
+	var opErr *operationError
+	if err := w.startWake(w.startSpan("wol.auto_wake", req), w.forwardedHealthHeaders(req)); errors.As(err, &opErr) {
+		switch opErr.code {
+		case codeAlreadyRunning:
+			// An earlier request already started the wake (or a power-off is running)
+			message = fmt.Sprintf("Service is not ready yet (%s), please retry shortly", opErr.message)
+		case codeRateLimited:
+			state = "suspended"
+			message = opErr.message
+			retryAfter = opErr.retryAfter
+		default:
+			state = "failed"
+			message = opErr.message
+		}
+	} else if err != nil {
+		state = "failed"
+		message = err.Error()
+	}
+
+	seconds := retryAfterSeconds(retryAfter)
+	rw.Header().Set("Retry-After", seconds)
+	rw.Header().Set("Cache-Control", "no-store")
+	if w.coldResponseBody != "" {
+		w.writeColdResponse(rw)
+		return
+	}
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(rw).Encode(map[string]interface{}{
+			"status":     state,
+			"message":    message,
+			"retryAfter": retryAfter.Seconds(),
+		})
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintf(rw, "<!DOCTYPE html>\n<html><head><meta charset=\"UTF-8\"><meta http-equiv=\"refresh\" content=\"%s\"><title>Service Unavailable</title></head>\n<body><p>%s</p></body></html>\n",
+		seconds, template.HTMLEscapeString(message))
+}
+
+// writeColdResponse answers a cold request with the configured coldResponseBody and a 503
+func (w *WOLPlugin) writeColdResponse(rw http.ResponseWriter) {
+	rw.Header().Set("Content-Type", w.coldResponseContentType)
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(http.StatusServiceUnavailable)
+	io.WriteString(rw, w.coldResponseBody)
+}
+
+// writeJSONResponse writes a JSON response
+func (w *WOLPlugin) writeJSONResponse(rw http.ResponseWriter, data interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(data); err != nil {
+		http.Error(rw, "JSON encoding error", http.StatusInternalServerError)
+	}
+}
+
+// performWakeSequence runs the wake sequence with status updates
+// When firstPacketSent is set the caller already sent the first attempt's packet.
+func (w *WOLPlugin) performWakeSequence(ctx context.Context, firstPacketSent bool) {
+	w.publishEvent("wake_started", nil)
+	span := spanFromContext(ctx)
+	attempts := 0
+	w.wakeMutex.RLock()
+	started := w.wakeCache.startTime
+	w.wakeMutex.RUnlock()
+	w.runWakeHook(w.currentWakeHooks().OnWakeStart, WakeEvent{Duration: w.now().Sub(started)})
+	defer func() {
+		w.wakeMutex.Lock()
+		if !w.isCurrentOperationLocked(ctx) {
+			w.wakeMutex.Unlock()
+			span.setAttribute("wol.attempts", attempts)
+			span.setError(errSupersededByForce.Error())
+			span.finish()
+			w.runWakeHook(w.currentWakeHooks().OnWakeFailure, WakeEvent{Attempts: attempts, Duration: w.now().Sub(started), Message: errSupersededByForce.Error()})
+			return
+		}
+		// Close before clearing isWaking so a wake started right after never finds these connections
+		w.closeWakeConnections()
+		w.wakeCache.isWaking = false
+		w.endOperationLocked()
+		success := w.wakeCache.progress == 100
+		result := map[string]interface{}{"success": success, "message": w.wakeCache.message}
+		w.notifyWakeChangeLocked()
+		w.wakeMutex.Unlock()
+		if w.dryRun {
+			w.abandonWakeAttempt()
+		} else {
+			w.recordWakeResult(ctx, success)
+		}
+		span.setAttribute("wol.attempts", attempts)
+		if !success {
+			span.setError(result["message"].(string))
+		}
+		span.finish()
+		w.publishEvent("wake_finished", result)
+
+		event := WakeEvent{Attempts: attempts, Duration: w.now().Sub(started), Message: result["message"].(string)}
+		if success {
+			w.runWakeHook(w.currentWakeHooks().OnWakeSuccess, event)
+		} else {
+			w.runWakeHook(w.currentWakeHooks().OnWakeFailure, event)
+		}
+	}()
+
+	fmt.Printf("WOL Plugin [%s]: Service unhealthy, attempting to wake %s\n", w.name, w.macAddress)
+
+	if w.preWakeWebhookURL != "" && !firstPacketSent {
+		w.wakeMutex.Lock()
+		w.wakeCache.message = "Calling pre-wake webhook..."
+		w.wakeCache.progress = 0
+		w.notifyWakeChangeLocked()
+		w.wakeMutex.Unlock()
+
+		if err := w.callPreWakeWebhook(ctx, span); err != nil {
+			if ctx.Err() != nil {
+				w.markCancelled(ctx, "Wake")
+				return
+			}
+			fmt.Printf("WOL Plugin [%s]: %v\n", w.name, err)
+			w.wakeMutex.Lock()
+			w.wakeCache.message = fmt.Sprintf("Wake aborted: %v", err)
+			w.recordWakeFailureLocked(err.Error())
+			w.notifyWakeChangeLocked()
+			w.wakeMutex.Unlock()
+			return
+		}
+	}
+
+	for attempt := 1; attempt <= w.retryAttempts; attempt++ {
+		attempts = attempt
+		w.wakeMutex.Lock()
+		w.wakeCache.message = fmt.Sprintf("Wake attempt %d/%d - Sending WOL packet...", attempt, w.retryAttempts)
+		w.wakeCache.progress = int(float64(attempt-1) / float64(w.retryAttempts) * float64(w.progressSendPct)) // 0-40% for sending packets by default
+		w.notifyWakeChangeLocked()
+		w.wakeMutex.Unlock()
+
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Wake attempt %d/%d\n", w.name, attempt, w.retryAttempts)
+		}
+
+		var err error
+		if attempt > 1 || !firstPacketSent {
+			err = w.sendWOLPacketTraced(span, attempt)
+		}
+		if err != nil {
+			fmt.Printf("WOL Plugin [%s]: Failed to send WOL packet (attempt %d): %v\n", w.name, attempt, err)
+			if attempt < w.retryAttempts {
+				delay := w.retryDelay(attempt)
+				w.wakeMutex.Lock()
+				w.wakeCache.message = fmt.Sprintf("Failed to send WOL packet (attempt %d): %v - retrying in %v", attempt, err, delay)
+				w.recordWakeFailureLocked(fmt.Sprintf("%v (attempt %d)", err, attempt))
+				w.notifyWakeChangeLocked()
+				w.wakeMutex.Unlock()
+				if !w.sleep(ctx, delay) {
+					w.markCancelled(ctx, "Wake")
+					return
+				}
+				continue
+			}
+			
+			w.wakeMutex.Lock()
+			w.wakeCache.message = "Failed to wake up service after all attempts"
+			w.recordWakeFailureLocked(fmt.Sprintf("%v (after %d attempts)", err, w.retryAttempts))
+			w.notifyWakeChangeLocked()
+			w.wakeMutex.Unlock()
+			return
+		}
+		if ctx.Err() != nil {
+			w.markCancelled(ctx, "Wake")
+			return
+		}
+
+		if w.dryRun {
+			// Nothing was sent, so there is nothing to wait for
+			w.wakeMutex.Lock()
+			w.wakeCache.message = "Dry run complete - no WOL packet was sent"
+			w.wakeCache.progress = 100
+			w.notifyWakeChangeLocked()
+			w.wakeMutex.Unlock()
+			fmt.Printf("WOL Plugin [%s]: Dry run wake sequence completed\n", w.name)
+			return
+		}
+
+		w.wakeMutex.Lock()
+		w.wakeCache.message = fmt.Sprintf("WOL packet sent (attempt %d/%d) - Waiting for service...", attempt, w.retryAttempts) + w.broadcastFallbackNoteLocked()
+		w.wakeCache.progress = w.progressSendPct + int(float64(attempt-1) / float64(w.retryAttempts) * float64(w.progressWaitPct-w.progressSendPct)) // 40-70% for waiting by default
+		w.notifyWakeChangeLocked()
+		w.wakeMutex.Unlock()
+
+		waitSpan := span.startChild("wol.wait_for_service")
+		waitSpan.setAttribute("wol.attempt", attempt)
+		healthy := w.waitForServiceWithProgress(ctx)
+		if !healthy {
+			waitSpan.setError("service did not become healthy before the timeout")
+		}
+		waitSpan.finish()
+		if healthy {
+			w.wakeMutex.Lock()
+			w.wakeCache.message = "Service is now online!"
+			w.wakeCache.progress = 100
+			w.wakeCache.lastError = ""
+			w.wakeCache.lastFailureTime = time.Time{}
+			w.notifyWakeChangeLocked()
+			w.wakeMutex.Unlock()
+			fmt.Printf("WOL Plugin [%s]: Service is now online\n", w.name)
+			return
+		}
+		if ctx.Err() != nil {
+			w.markCancelled(ctx, "Wake")
+			return
+		}
+
+		if attempt < w.retryAttempts {
+			delay := w.retryDelay(attempt)
+			fmt.Printf("WOL Plugin [%s]: Service not responding, retrying in %v\n", w.name, delay)
+			w.wakeMutex.Lock()
+			w.wakeCache.message = fmt.Sprintf("Service not responding, retrying in %v", delay)
+			w.recordWakeFailureLocked(fmt.Sprintf("service did not respond within %v (attempt %d)", w.timeout, attempt))
+			w.notifyWakeChangeLocked()
+			w.wakeMutex.Unlock()
+			if !w.sleep(ctx, delay) {
+				w.markCancelled(ctx, "Wake")
+				return
+			}
+		}
+	}
+
+	fmt.Printf("WOL Plugin [%s]: Service did not come online after %d attempts\n", w.name, w.retryAttempts)
+	w.wakeMutex.Lock()
+	w.wakeCache.message = fmt.Sprintf("Service did not come online after %d attempts", w.retryAttempts)
+	w.recordWakeFailureLocked(fmt.Sprintf("service did not come online after %d attempts", w.retryAttempts))
+	w.notifyWakeChangeLocked()
+	w.wakeMutex.Unlock()
+}
+
+// recordWakeFailureLocked remembers why the latest wake attempt failed; the caller must hold wakeMutex for writing
+func (w *WOLPlugin) recordWakeFailureLocked(reason string) {
+	w.wakeCache.lastError = reason
+	w.wakeCache.lastFailureTime = w.now()
+}
+
+// Wake progress phases: sending packets fills 0 to progressSendPct, each sent attempt advances towards
+// progressWaitPct, and polling the health check climbs from there but stops at maxWaitProgress until healthy
+const (
+	defaultProgressSendPct = 40
+	defaultProgressWaitPct = 70
+	maxWaitProgress        = 95
+)
+
+// parseProgressPct parses a progress phase boundary, which must lie within 0 to maxWaitProgress
+func parseProgressPct(field, value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	pct, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", field, err)
+	}
+	if pct < 0 || pct > maxWaitProgress {
+		return 0, fmt.Errorf("%s must be between 0 and %d", field, maxWaitProgress)
+	}
+	return pct, nil
+}
+
+// sleepContext waits for d, returning false early if ctx is cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// markCancelled records that an operation was aborted, either by the user or because the middleware is shutting down
+func (w *WOLPlugin) markCancelled(ctx context.Context, operation string) {
+	reason := "cancelled: plugin is shutting down"
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, errCancelledByUser):
+		reason = "cancelled by user"
+	case errors.Is(cause, errSupersededByForce):
+		reason = errSupersededByForce.Error()
+	}
+	fmt.Printf("WOL Plugin [%s]: %s sequence %s\n", w.name, operation, reason)
+	w.wakeMutex.Lock()
+	if w.isCurrentOperationLocked(ctx) {
+		w.wakeCache.message = operation + " " + reason
+		w.notifyWakeChangeLocked()
+	}
+	w.wakeMutex.Unlock()
+}
+
+// Retry backoff strategies for the interval between wake attempts
+const (
+	retryBackoffFixed       = "fixed"
+	retryBackoffLinear      = "linear"
+	retryBackoffExponential = "exponential"
+)
+
+// retryDelay returns how long to wait after the given (1-based) failed attempt, capped at retryMaxInterval
+func (w *WOLPlugin) retryDelay(attempt int) time.Duration {
+	delay := w.retryInterval
+	switch w.retryBackoff {
+	case retryBackoffLinear:
+		delay = w.retryInterval * time.Duration(attempt)
+	case retryBackoffExponential:
+		for i := 1; i < attempt; i++ {
+			delay *= 2
+			if w.retryMaxInterval > 0 && delay >= w.retryMaxInterval {
+				break
+			}
+		}
+	}
+
+	if w.retryMaxInterval > 0 && delay > w.retryMaxInterval {
+		delay = w.retryMaxInterval
+	}
+	return delay
+}
+
+// waitForServiceWithProgress waits for service with progress updates
+func (w *WOLPlugin) waitForServiceWithProgress(ctx context.Context) bool {
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: Waiting for service to come online (timeout: %v)\n", w.name, w.timeout)
+	}
+	
+	start := w.now()
+	healthyProbes := 0
+	
+	for probe := 1; w.now().Sub(start) < w.timeout; probe++ {
+		live, ready := w.checkServiceReady(healthHeadersFromContext(ctx))
+		if ctx.Err() != nil {
+			return false
+		}
+		if live && ready {
+			if healthyProbes++; healthyProbes >= w.wakeHealthyThreshold {
+				return true
+			}
+		} else {
+			healthyProbes = 0
+		}
+		
+		// Update progress during wait
+		elapsed := w.now().Sub(start)
+		progress := w.progressWaitPct + int(float64(elapsed)/float64(w.timeout)*float64(100-w.progressWaitPct)) // 70-100% for waiting by default
+		// While confirming stability, progress also moves with the healthy probes seen so far
+		if healthyProbes > 0 {
+			if confirmed := w.progressWaitPct + (maxWaitProgress-w.progressWaitPct)*healthyProbes/w.wakeHealthyThreshold; confirmed > progress {
+				progress = confirmed
+			}
+		}
+		if progress > maxWaitProgress {
+			progress = maxWaitProgress // Cap at 95% until actually healthy
+		}
+		
+		w.wakeMutex.Lock()
+		if progress > w.wakeCache.progress {
+			w.wakeCache.progress = progress
+		}
+		remaining := w.timeout - elapsed
+		if healthyProbes > 0 {
+			w.wakeCache.message = fmt.Sprintf("Service responding, confirming it is stable (%d/%d healthy checks)...", healthyProbes, w.wakeHealthyThreshold)
+		} else if live {
+			w.wakeCache.message = fmt.Sprintf("Service up, waiting for readiness... (%v remaining)", remaining.Truncate(time.Second))
+		} else {
+			switch w.lastDialFailure() {
+			case dialRefused:
+				w.wakeCache.message = fmt.Sprintf("Host responding, waiting for service... (%v remaining)", remaining.Truncate(time.Second))
+			case dialUnreachable:
+				w.wakeCache.message = fmt.Sprintf("Host not yet reachable, waiting... (%v remaining)", remaining.Truncate(time.Second))
+			default:
+				w.wakeCache.message = fmt.Sprintf("Waiting for service... (%v remaining)", remaining.Truncate(time.Second))
+			}
+		}
+		w.wakeCache.message += w.broadcastFallbackNoteLocked()
+		w.notifyWakeChangeLocked()
+		w.wakeMutex.Unlock()
+		
+		if !w.sleep(ctx, w.wakePollDelay(probe)) {
+			return false
+		}
+	}
+	return false
+}
+
+// checkServiceReady refreshes the health status, with forwarded on the probe, and once the service is live
+// probes readinessCheck too. Without a readinessCheck a live service counts as ready.
+func (w *WOLPlugin) checkServiceReady(forwarded http.Header) (live, ready bool) {
+	if !w.sharedHealthCheckWithHeaders(false, forwarded) {
+		return false, false
+	}
+	if w.readinessCheck == "" {
+		return true, true
+	}
+	return true, w.checkHealthURL(w.readinessCheck)
+}
+
+// CSRF tokens follow the double-submit pattern: the control page sets a cookie and echoes
+// the same value in a header or form field, which a cross-site request cannot read
+const (
+	csrfCookieName = "_wol_csrf"
+	csrfHeaderName = "X-WOL-CSRF-Token"
+	csrfFormField  = "csrf_token"
+	csrfTokenBytes = 32
+)
+
+// Bypass session cookies hold their expiry in Unix seconds and an HMAC-SHA256 of it, keyed per plugin instance
+const (
+	bypassCookieName      = "_wol_bypass"
+	bypassSessionKeyBytes = 32
+)
+
+// bypassSessionSignature signs a bypass session expiry
+func (w *WOLPlugin) bypassSessionSignature(expires string) string {
+	w.bypassMutex.RLock()
+	mac := hmac.New(sha256.New, w.bypassSessionKey)
+	w.bypassMutex.RUnlock()
+	mac.Write([]byte(expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// setBypassSession gives the client a cookie that skips the control page for bypassSessionDuration
+func (w *WOLPlugin) setBypassSession(rw http.ResponseWriter, req *http.Request) {
+	if w.bypassSessionDuration <= 0 {
+		return
+	}
+	expires := w.now().Add(w.bypassSessionDuration)
+	value := strconv.FormatInt(expires.Unix(), 10)
+	http.SetCookie(rw, &http.Cookie{
+		Name:     bypassCookieName,
+		Value:    value + "." + w.bypassSessionSignature(value),
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(w.bypassSessionDuration.Seconds()),
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// validBypassSession reports whether req carries an unexpired bypass session cookie signed by this instance
+func (w *WOLPlugin) validBypassSession(req *http.Request) bool {
+	if w.bypassSessionDuration <= 0 || req == nil {
+		return false
+	}
+	cookie, err := req.Cookie(bypassCookieName)
+	if err != nil {
+		return false
+	}
+	value, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(w.bypassSessionSignature(value))) {
+		return false
+	}
+	expires, err := strconv.ParseInt(value, 10, 64)
+	return err == nil && w.now().Unix() < expires
+}
+
+// originalURLFormField carries the URL the user requested through the "Go to Service" redirect
+const originalURLFormField = "original_url"
+
+// csrfToken returns the session's CSRF token from its cookie, issuing a new one if absent
+func (w *WOLPlugin) csrfToken(rw http.ResponseWriter, req *http.Request) (string, error) {
+	if cookie, err := req.Cookie(csrfCookieName); err == nil && isCSRFToken(cookie.Value) {
+		return cookie.Value, nil
+	}
+
+	raw := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
+
+// isCSRFToken reports whether value has the shape of a token issued by csrfToken
+func isCSRFToken(value string) bool {
+	if len(value) != csrfTokenBytes*2 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+// validCSRFToken checks that the request echoes its CSRF cookie; it always passes when protection is disabled
+func (w *WOLPlugin) validCSRFToken(req *http.Request) bool {
+	if !w.enableCSRFProtection {
+		return true
+	}
+
+	cookie, err := req.Cookie(csrfCookieName)
+	if err != nil || !isCSRFToken(cookie.Value) {
+		return false
+	}
+
+	submitted := req.Header.Get(csrfHeaderName)
+	if submitted == "" {
+		submitted = req.PostFormValue(csrfFormField)
+	}
+	return subtle.ConstantTimeCompare([]byte(submitted), []byte(cookie.Value)) == 1
+}
+
+// handleRedirectEndpoint handles POST requests to /_wol/redirect
+func (w *WOLPlugin) handleRedirectEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !w.validCSRFToken(req) {
+		w.writeOperationError(rw, errCSRFInvalid)
+		return
+	}
+
+	// Set bypass state with 5-second expiration
+	w.bypassMutex.Lock()
+	w.bypassCache.isBypass = true
+	w.bypassCache.startTime = w.now()
+	w.bypassMutex.Unlock()
+	w.setBypassSession(rw, req)
+
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: Redirect request received, bypass state set\n", w.name)
+	}
+
+	// Return the user to the URL they originally requested. The request body can't be replayed, so
+	// a POST that landed on the control page continues as a GET to the same path and query, unless
+	// redirectStatusCode is 307 or 308 and the browser repeats this form's POST there instead.
+	redirectURL := safeRedirectPath(req.FormValue(originalURLFormField))
+	if w.redirectTarget != "" {
+		redirectURL = w.redirectTarget
+	} else if origin := w.forwardedOrigin(req); origin != "" {
+		redirectURL = origin + redirectURL
+	}
+	
+	http.Redirect(rw, req, redirectURL, w.redirectStatusCode)
+}
+
+// forwardedOrigin returns the scheme and host the client used, taken from X-Forwarded-Host and
+// X-Forwarded-Proto when trustForwardedFor is set, so redirects can be absolute behind TLS termination or
+// host rewriting. Like clientIP it trusts the last value added. It returns "" when there is no usable host.
+func (w *WOLPlugin) forwardedOrigin(req *http.Request) string {
+	if !w.trustForwardedFor {
+		return ""
+	}
+	host := lastForwardedValue(req, "X-Forwarded-Host")
+	if host == "" {
+		return ""
+	}
+
+	scheme := strings.ToLower(lastForwardedValue(req, "X-Forwarded-Proto"))
+	if scheme != "http" && scheme != "https" {
+		scheme = "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+	}
+
+	// Reject anything that isn't a bare host[:port], such as "evil.example/path" or "user@host"
+	parsed, err := url.Parse(scheme + "://" + host)
+	if err != nil || parsed.Host != host || parsed.User != nil || parsed.Path != "" {
+		return ""
+	}
+	return scheme + "://" + host
+}
+
+// lastForwardedValue returns the last comma-separated entry of the named header
+func lastForwardedValue(req *http.Request, header string) string {
+	values := req.Header.Values(header)
+	if len(values) == 0 {
+		return ""
+	}
+	entries := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(entries[len(entries)-1])
+}
+
+// safeRedirectPath returns raw when it is a local path outside /_wol/, otherwise "/", so the redirect
+// endpoint can't be used to send users to another site or loop back into the control endpoints
+func safeRedirectPath(raw string) string {
+	if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") || strings.HasPrefix(raw, "/\\") {
+		return "/"
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" || strings.HasPrefix(parsed.Path, "/_wol/") {
+		return "/"
+	}
+	return raw
+}
+
+// handlePowerOffEndpoint handles POST requests to /_wol/poweroff
+func (w *WOLPlugin) handlePowerOffEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !w.validCSRFToken(req) {
+		w.writeOperationError(rw, errCSRFInvalid)
+		return
+	}
+
+	err := w.PowerOff(req.Context())
+	w.audit(req, auditActionPowerOff, err)
+	if err != nil {
+		w.writeOperationError(rw, err)
+		return
+	}
+
+	w.writeJSONResponse(rw, map[string]interface{}{
+		"success": true,
+		"message": "Power-off process started",
+	})
+}
+
+// handleAdminPowerOffEndpoint handles POST requests to /_wol/admin/poweroff for scripted clients. It is
+// authenticated with the adminToken bearer token instead of the control page's CSRF token, and disabled
+// unless adminToken is configured.
+func (w *WOLPlugin) handleAdminPowerOffEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if !w.authorizeAdminEndpoint(rw, req) {
+		return
+	}
+
+	err := w.PowerOff(req.Context())
+	w.audit(req, auditActionPowerOff, err)
+	if err != nil {
+		w.writeOperationError(rw, err)
+		return
+	}
+
+	fmt.Printf("WOL Plugin [%s]: Power-off requested via admin API from %s\n", w.name, w.clientIP(req))
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(rw).Encode(map[string]interface{}{
+		"success":   true,
+		"operation": "power-off",
+		"message":   "Power-off process started",
+		"statusUrl": "/_wol/status",
+	})
+}
+
+// authorizeAdminEndpoint gates the POST-only /_wol/admin/ endpoints: hidden without adminToken and requiring it
+// as a bearer token otherwise. It writes the rejection itself and reports whether to continue.
+func (w *WOLPlugin) authorizeAdminEndpoint(rw http.ResponseWriter, req *http.Request) bool {
+	if w.adminToken == "" {
+		http.NotFound(rw, req)
+		return false
+	}
+	if req.Method != http.MethodPost {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if !w.validAdminToken(req) {
+		rw.Header().Set("WWW-Authenticate", `Bearer realm="wol-admin"`)
+		w.writeOperationError(rw, &operationError{
+			code:    codeUnauthorized,
+			status:  http.StatusUnauthorized,
+			message: "missing or invalid admin token",
+		})
+		return false
+	}
+	return true
+}
+
+// handleStatsEndpoint handles GET requests to /_wol/stats with the service's availability since the first
+// health result: time spent healthy and unhealthy, the number of outages and the last transition
+func (w *WOLPlugin) handleStatsEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.writeJSONResponse(rw, w.statsResponse())
+}
+
+// handleAdminStatsResetEndpoint handles POST requests to /_wol/admin/stats/reset, answering with the
+// statistics as they were and starting over from the next health result
+func (w *WOLPlugin) handleAdminStatsResetEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if !w.authorizeAdminEndpoint(rw, req) {
+		return
+	}
+	response := w.statsResponse()
+	w.statsMutex.Lock()
+	w.stats = availabilityStats{}
+	w.statsMutex.Unlock()
+
+	fmt.Printf("WOL Plugin [%s]: Availability statistics reset via admin API from %s\n", w.name, w.clientIP(req))
+	w.writeJSONResponse(rw, response)
+}
+
+// handleResetEndpoint handles POST requests to /_wol/reset, clearing the health, wake and bypass state as if the
+// plugin had just loaded. A running wake or power-off is abandoned. It answers with the state as it was before.
+func (w *WOLPlugin) handleResetEndpoint(rw http.ResponseWriter, req *http.Request) {
+	if !w.authorizeAdminEndpoint(rw, req) {
+		return
+	}
+	response := w.resetState()
+
+	fmt.Printf("WOL Plugin [%s]: Health, wake and bypass state reset via admin API from %s\n", w.name, w.clientIP(req))
+	w.writeJSONResponse(rw, response)
+}
+
+// resetState returns the plugin to its initial health, wake and bypass state, describing the state it replaced
+func (w *WOLPlugin) resetState() map[string]interface{} {
+	formatTime := func(t time.Time) interface{} {
+		if t.IsZero() {
+			return nil
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	w.healthMutex.Lock()
+	health := *w.healthCache
+	w.healthCache = &healthStatus{} // the next request probes afresh
+	w.healthMutex.Unlock()
+
+	w.wakeMutex.RLock()
+	wake := *w.wakeCache
+	w.wakeMutex.RUnlock()
+	w.forceResetOperation()
+	w.wakeMutex.Lock()
+	w.wakeCache.lastError = ""
+	w.wakeCache.lastFailureTime = time.Time{}
+	w.notifyWakeChangeLocked()
+	w.wakeMutex.Unlock()
+
+	w.bypassMutex.Lock()
+	bypass := *w.bypassCache
+	w.bypassCache = &bypassStatus{}
+	if w.bypassSessionKey != nil {
+		// A new key invalidates every bypass session cookie handed out so far
+		rand.Read(w.bypassSessionKey)
+	}
+	w.bypassMutex.Unlock()
+
+	return map[string]interface{}{
+		"health": map[string]interface{}{
+			"isHealthy": health.isHealthy,
+			"lastCheck": formatTime(health.lastCheck),
+		},
+		"wake": map[string]interface{}{
+			"isWaking":        wake.isWaking,
+			"isPoweringOff":   wake.isPoweringOff,
+			"startTime":       formatTime(wake.startTime),
+			"message":         wake.message,
+			"progress":        wake.progress,
+			"lastError":       wake.lastError,
+			"lastFailureTime": formatTime(wake.lastFailureTime),
+		},
+		"bypass": map[string]interface{}{
+			"isBypass":  bypass.isBypass,
+			"startTime": formatTime(bypass.startTime),
+		},
+	}
+}
+
+// statsResponse builds the /_wol/stats payload; times are null until they have happened
+func (w *WOLPlugin) statsResponse() map[string]interface{} {
+	stats := w.availabilitySnapshot()
+	var observedSince, lastTransition, availability interface{}
+	if !stats.observedSince.IsZero() {
+		observedSince = stats.observedSince.UTC().Format(time.RFC3339)
+	}
+	if !stats.lastTransition.IsZero() {
+		lastTransition = stats.lastTransition.UTC().Format(time.RFC3339)
+	}
+	if total := stats.healthyTotal + stats.unhealthyTotal; total > 0 {
+		availability = float64(stats.healthyTotal) / float64(total)
+	}
+	return map[string]interface{}{
+		"isHealthy":        stats.isHealthy,
+		"observedSince":    observedSince,
+		"healthySeconds":   stats.healthyTotal.Seconds(),
+		"unhealthySeconds": stats.unhealthyTotal.Seconds(),
+		"availability":     availability,
+		"outages":          stats.outages,
+		"lastTransition":   lastTransition,
+	}
+}
+
+// validAdminToken reports whether the request carries adminToken as an Authorization bearer token
+func (w *WOLPlugin) validAdminToken(req *http.Request) bool {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(w.adminToken)) == 1
+}
+
+// startPowerOff launches the power-off sequence in the background unless another operation is running
+// or no operation slot is free
+func (w *WOLPlugin) startPowerOff() error {
+	w.wakeMutex.Lock()
+	if w.wakeCache.isWaking || w.wakeCache.isPoweringOff {
+		processType := "power-off"
+		if w.wakeCache.isWaking {
+			processType = "wake"
+		}
+		w.wakeMutex.Unlock()
+		return &operationError{
+			code:    codeAlreadyRunning,
+			status:  http.StatusConflict,
+			message: fmt.Sprintf("%s process already in progress", processType),
+		}
+	}
+	if !w.acquireOperationSlot() {
+		w.wakeMutex.Unlock()
+		return errTooManyOperations
+	}
+
+	w.wakeCache.isPoweringOff = true
+	w.wakeCache.isWaking = false
+	w.wakeCache.startTime = w.now()
+	w.wakeCache.message = "Initiating power-off sequence..."
+	w.wakeCache.progress = 0
+	ctx := w.beginOperationLocked()
+	slot := w.holdOperationSlotLocked()
+	w.notifyWakeChangeLocked()
+	w.wakeMutex.Unlock()
+
+	// Start power-off process in background
+	go func() {
+		defer slot.release()
+		w.performPowerOffSequence(ctx)
+	}()
+
+	return nil
+}
+
+// acquireOperationSlot claims one of the maxConcurrentOperations slots without waiting
+func (w *WOLPlugin) acquireOperationSlot() bool {
+	select {
+	case w.operationSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseOperationSlot frees a slot claimed by acquireOperationSlot
+func (w *WOLPlugin) releaseOperationSlot() {
+	<-w.operationSlots
+}
+
+// operationSlot is an operation slot claimed for one sequence. It is released once, by the sequence when it
+// exits or by a forced wake superseding it, whichever comes first.
+type operationSlot struct {
+	once sync.Once
+	w    *WOLPlugin
+}
+
+// release frees the slot unless it was already freed
+func (s *operationSlot) release() {
+	s.once.Do(s.w.releaseOperationSlot)
+}
+
+// holdOperationSlotLocked records the slot just claimed with acquireOperationSlot as held by the operation
+// starting now; the caller must hold wakeMutex
+func (w *WOLPlugin) holdOperationSlotLocked() *operationSlot {
+	w.operationSlot = &operationSlot{w: w}
+	return w.operationSlot
+}
+
+// recordActivity marks the current time as the last request passed through to the service
+func (w *WOLPlugin) recordActivity() {
+	w.activityMutex.Lock()
+	w.lastActivity = w.now()
+	w.activityMutex.Unlock()
+}
+
+// getLastActivity returns the time of the last request passed through to the service
+func (w *WOLPlugin) getLastActivity() time.Time {
+	w.activityMutex.RLock()
+	defer w.activityMutex.RUnlock()
+	return w.lastActivity
+}
+
+// idleCheckInterval returns how often the idle monitor evaluates activity
+func (w *WOLPlugin) idleCheckInterval() time.Duration {
+	interval := w.idleShutdownTimeout / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	return interval
+}
+
+// runIdleShutdownMonitor checks for idleness until ctx is cancelled
+func (w *WOLPlugin) runIdleShutdownMonitor(ctx context.Context) {
+	ticker := time.NewTicker(w.idleCheckInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.checkIdleShutdown()
+		}
+	}
+}
+
+// checkIdleShutdown powers off a healthy service that has seen no traffic for idleShutdownTimeout
+func (w *WOLPlugin) checkIdleShutdown() bool {
+	if w.idleShutdownTimeout <= 0 {
+		return false
+	}
+
+	now := w.now()
+	idle := now.Sub(w.getLastActivity())
+	if idle < w.idleShutdownTimeout {
+		return false
+	}
+
+	// Scheduled awake windows take precedence over idleness
+	if w.inAwakeWindow(now) {
+		return false
+	}
+
+	if !w.getCachedHealthStatus() {
+		return false
+	}
+
+	if err := w.startPowerOff(); err != nil {
+		return false
+	}
+
+	fmt.Printf("WOL Plugin [%s]: No traffic for %v, powering off idle service\n", w.name, idle.Truncate(time.Second))
+
+	// Restart the idle window so the monitor doesn't re-trigger while the service shuts down
+	w.recordActivity()
+	return true
+}
+
+// defaultKeepAliveIdleTimeout bounds keep-alives when idleShutdownTimeout is not set
+const defaultKeepAliveIdleTimeout = 10 * time.Minute
+
+// runKeepAliveMonitor sends keep-alives every keepAliveInterval until ctx is cancelled
+func (w *WOLPlugin) runKeepAliveMonitor(ctx context.Context) {
+	ticker := time.NewTicker(w.keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.checkKeepAlive()
+		}
+	}
+}
+
+// checkKeepAlive probes a healthy service that has seen traffic within keepAliveIdleTimeout, so a backend that
+// sleeps on its own stays up while it is in use. It never wakes the service and reports whether it sent a probe.
+func (w *WOLPlugin) checkKeepAlive() bool {
+	if w.keepAliveInterval <= 0 {
+		return false
+	}
+	if w.now().Sub(w.getLastActivity()) >= w.keepAliveIdleTimeout {
+		return false
+	}
+
+	// Leave wakes and power-offs alone; keeping a service up that is being shut down would fight the power-off
+	w.wakeMutex.RLock()
+	busy := w.wakeCache.isWaking || w.wakeCache.isPoweringOff
+	w.wakeMutex.RUnlock()
+	if busy {
+		return false
+	}
+
+	if w.keepAliveURL == "" {
+		// The probe itself is the keep-alive; it also refreshes the health cache
+		w.sharedHealthCheck(false)
+		return true
+	}
+	if !w.getCachedHealthStatus() {
+		return false
+	}
+
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodGet, w.keepAliveURL, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", w.healthCheckUserAgent)
+	req.Header.Set("Cache-Control", "no-cache")
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Keep-alive request failed: %v\n", w.name, err)
+		}
+		return true
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if w.debug {
+		fmt.Printf("WOL Plugin [%s]: Keep-alive returned status %d\n", w.name, resp.StatusCode)
+	}
+	return true
+}
+
+// scheduleWindow is a recurring awake window on a set of weekdays, in minutes since midnight.
+// A window whose end is before its start spans midnight into the following day.
+type scheduleWindow struct {
+	days  [7]bool
+	start int
+	end   int
+}
+
+// String formats the window the way schedule entries are written, e.g. "Mon,Tue,Wed 08:00-18:00"
+func (window scheduleWindow) String() string {
+	var days []string
+	for day, awake := range window.days {
+		if awake {
+			days = append(days, time.Weekday(day).String()[:3])
+		}
+	}
+	return fmt.Sprintf("%s %02d:%02d-%02d:%02d", strings.Join(days, ","), window.start/60, window.start%60, window.end/60, window.end%60)
+}
+
+// weekdayNames maps schedule day abbreviations to weekdays
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseSchedule parses schedule entries of the form "Mon-Fri 08:00-18:00", "Sat,Sun 10:00-14:00" or "Daily 22:00-02:00"
+func parseSchedule(entries []string) ([]scheduleWindow, error) {
+	var windows []scheduleWindow
+	for _, entry := range entries {
+		window, err := parseScheduleWindow(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule entry %q: %v", entry, err)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func parseScheduleWindow(entry string) (scheduleWindow, error) {
+	var window scheduleWindow
+
+	fields := strings.Fields(entry)
+	if len(fields) != 2 {
+		return window, fmt.Errorf("expected \"<days> <HH:MM>-<HH:MM>\"")
+	}
+
+	if err := parseScheduleDays(fields[0], &window.days); err != nil {
+		return window, err
+	}
+
+	times := strings.Split(fields[1], "-")
+	if len(times) != 2 {
+		return window, fmt.Errorf("expected time range \"HH:MM-HH:MM\"")
+	}
+	var err error
+	if window.start, err = parseClockMinutes(times[0]); err != nil {
+		return window, err
+	}
+	if window.end, err = parseClockMinutes(times[1]); err != nil {
+		return window, err
+	}
+	if window.start == window.end {
+		return window, fmt.Errorf("time range must not be empty")
+	}
+
+	return window, nil
+}
+
+// parseScheduleDays parses a comma-separated list of days or day ranges into days
+func parseScheduleDays(spec string, days *[7]bool) error {
+	lower := strings.ToLower(spec)
+	if lower == "daily" || lower == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return nil
+	}
+
+	for _, part := range strings.Split(lower, ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("invalid day range %q", part)
+		}
+
+		first, ok := weekdayNames[bounds[0]]
+		if !ok {
+			return fmt.Errorf("unknown day %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdayNames[bounds[1]]; !ok {
+				return fmt.Errorf("unknown day %q", bounds[1])
+			}
+		}
+
+		// Ranges may wrap around the week, e.g. "Fri-Mon"
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClockMinutes parses "HH:MM" into minutes since midnight, allowing "24:00" as end of day
+func parseClockMinutes(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	total := hours*60 + minutes
+	if hours < 0 || minutes < 0 || minutes > 59 || total > 24*60 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return total, nil
+}
+
+// contains reports whether t falls inside the window
+func (sw scheduleWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	if sw.start < sw.end {
+		return sw.days[day] && minute >= sw.start && minute < sw.end
+	}
+
+	// Overnight window: the evening part belongs to the listed day, the morning part to the day after
+	if sw.days[day] && minute >= sw.start {
+		return true
+	}
+	return sw.days[(day+6)%7] && minute < sw.end
+}
+
+// inAwakeWindow reports whether t falls inside any scheduled awake window
+func (w *WOLPlugin) inAwakeWindow(t time.Time) bool {
+	if len(w.schedule) == 0 {
+		return false
+	}
+
+	local := t.In(w.scheduleLocation)
+	for _, window := range w.schedule {
+		if window.contains(local) {
+			return true
+		}
+	}
+	return false
+}
+
+// runScheduleMonitor evaluates the schedule until ctx is cancelled
+func (w *WOLPlugin) runScheduleMonitor(ctx context.Context) {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	w.checkSchedule()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.checkSchedule()
+		}
+	}
+}
+
+// checkSchedule keeps the service awake during scheduled windows, sending a magic packet
+// whenever it is found unhealthy inside one. It reports whether a packet was sent.
+func (w *WOLPlugin) checkSchedule() bool {
+	inWindow := w.inAwakeWindow(w.now())
+
+	w.scheduleMutex.Lock()
+	entering := inWindow && !w.scheduleAwake
+	leaving := !inWindow && w.scheduleAwake
+	w.scheduleAwake = inWindow
+	w.scheduleMutex.Unlock()
+
+	if entering {
+		fmt.Printf("WOL Plugin [%s]: Entering scheduled awake window\n", w.name)
+	}
+	if leaving {
+		fmt.Printf("WOL Plugin [%s]: Leaving scheduled awake window, idle shutdown permitted\n", w.name)
+	}
+
+	if !inWindow || w.maintenanceMode || w.getCachedHealthStatus() {
+		return false
+	}
+
+	w.wakeMutex.RLock()
+	busy := w.wakeCache.isWaking || w.wakeCache.isPoweringOff
+	w.wakeMutex.RUnlock()
+	if busy {
+		return false
+	}
+
+	err := w.sendWOLPacket()
+	w.closeWakeConnections()
+	if err != nil {
+		fmt.Printf("WOL Plugin [%s]: Scheduled wake failed: %v\n", w.name, err)
+		return false
+	}
+
+	fmt.Printf("WOL Plugin [%s]: Service unhealthy during scheduled awake window, magic packet sent\n", w.name)
+	return true
+}
+
+// performPowerOffSequence executes the power-off command based on the configured method
+func (w *WOLPlugin) performPowerOffSequence(ctx context.Context) {
+	w.publishEvent("poweroff_started", nil)
+	defer func() {
+		w.wakeMutex.Lock()
+		if !w.isCurrentOperationLocked(ctx) {
+			w.wakeMutex.Unlock()
+			return
+		}
+		w.wakeCache.isPoweringOff = false
+		w.draining = false
+		w.endOperationLocked()
+		result := map[string]interface{}{"success": w.wakeCache.progress == 100, "message": w.wakeCache.message}
+		w.notifyWakeChangeLocked()
+		w.wakeMutex.Unlock()
+		w.publishEvent("poweroff_finished", result)
+	}()
 
-    <script>
-        let isWaking = false;
-        let isPoweringOff = false;
-        let pollInterval;
-        let autoRedirect = {{.AutoRedirect}};
-        let redirectDelay = {{.RedirectDelaySeconds}};
-        let confirmPowerOff = {{.ConfirmPowerOff}};
-        
-        function updateStatus(status) {
-            const indicator = document.getElementById('statusIndicator');
-            const statusText = document.getElementById('statusText');
-            const progressContainer = document.getElementById('progressContainer');
-            const progressFill = document.getElementById('progressFill');
-            const progressDetails = document.getElementById('progressDetails');
-            const wakeBtn = document.getElementById('wakeBtn');
-            const powerOffBtn = document.getElementById('powerOffBtn');
-            
-            indicator.className = 'status-indicator ' + 
-                (status.isHealthy ? 'status-up' : 
-                 status.isWaking ? 'status-waking' : 'status-down');
-            
-            if (status.isHealthy) {
-                statusText.textContent = 'Service is online and ready!';
-                progressContainer.classList.add('hidden');
-                wakeBtn.disabled = true;
-                wakeBtn.textContent = '✅ Service Online';
-                if (powerOffBtn) {
-                    powerOffBtn.disabled = false;
-                    powerOffBtn.textContent = '⏻ Power Off';
-                }
-                
-                // Auto-redirect if enabled
-                if (autoRedirect) {
-                    statusText.textContent = 'Service is online! Redirecting in ' + redirectDelay + ' seconds...';
-                    setTimeout(() => {
-                        goToService();
-                    }, redirectDelay * 1000);
-                }
-            } else if (status.isWaking) {
-                statusText.textContent = status.message || 'Waking up service...';
-                progressContainer.classList.remove('hidden');
-                
-                progressFill.style.width = (status.progress || 0) + '%';
-                progressDetails.textContent = 'Wake process in progress...';
-                
-                wakeBtn.disabled = true;
-                wakeBtn.textContent = '⏳ Waking Up...';
-                if (powerOffBtn) {
-                    powerOffBtn.disabled = true;
-                    powerOffBtn.textContent = '⏻ Power Off';
-                }
-            } else if (status.isPoweringOff) {
-                statusText.textContent = status.message || 'Powering off service...';
-                progressContainer.classList.remove('hidden');
-                
-                progressFill.style.width = (status.progress || 0) + '%';
-                progressDetails.textContent = 'Power-off process in progress...';
-                
-                wakeBtn.disabled = true;
-                wakeBtn.textContent = '🚀 Turn On Service';
-                if (powerOffBtn) {
-                    powerOffBtn.disabled = true;
-                    powerOffBtn.textContent = '⏳ Powering Off...';
-                }
-            } else {
-                statusText.textContent = status.message || 'Service is currently offline';
-                progressContainer.classList.add('hidden');
-                wakeBtn.disabled = false;
-                wakeBtn.textContent = '🚀 Turn On Service';
-                if (powerOffBtn) {
-                    powerOffBtn.disabled = false;
-                    powerOffBtn.textContent = '⏻ Power Off';
-                }
-                isWaking = false;
-                isPoweringOff = false;
-                if (pollInterval) {
-                    clearInterval(pollInterval);
-                    pollInterval = null;
-                }
-            }
-        }
-        
-        function wakeService() {
-            if (isWaking || isPoweringOff) return;
-            
-            isWaking = true;
-            
-            fetch('/_wol/wake', {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json'
-                }
-            })
-            .then(response => response.json())
-            .then(data => {
-                if (data.success) {
-                    pollStatus();
-                } else {
-                    updateStatus({
-                        isHealthy: false,
-                        isWaking: false,
-                        message: data.message || 'Failed to start wake process'
-                    });
-                }
-            })
-            .catch(err => {
-                updateStatus({
-                    isHealthy: false,
-                    isWaking: false,
-                    message: 'Error starting wake process'
-                });
-            });
-        }
-        
-        function powerOffService() {
-            if (isWaking || isPoweringOff) return;
-            
-            if (confirmPowerOff && !confirm('Are you sure you want to power off the service?')) {
-                return;
-            }
-            
-            isPoweringOff = true;
-            
-            fetch('/_wol/poweroff', {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json'
-                }
-            })
-            .then(response => response.json())
-            .then(data => {
-                if (data.success) {
-                    pollStatus();
-                } else {
-                    updateStatus({
-                        isHealthy: false,
-                        isPoweringOff: false,
-                        message: data.message || 'Failed to start power-off process'
-                    });
-                }
-            })
-            .catch(err => {
-                updateStatus({
-                    isHealthy: false,
-                    isPoweringOff: false,
-                    message: 'Error starting power-off process'
-                });
-            });
-        }
-        
-        function pollStatus() {
-            if (pollInterval) clearInterval(pollInterval);
-            
-            pollInterval = setInterval(() => {
-                fetch('/_wol/status')
-                .then(response => response.json())
-                .then(data => {
-                    updateStatus(data);
-                    if (data.isHealthy || (!data.isWaking && !data.isPoweringOff)) {
-                        clearInterval(pollInterval);
-                        pollInterval = null;
-                    }
-                })
-                .catch(err => {
-                    console.error('Error polling status:', err);
-                });
-            }, 2000);
-        }
-        
-        function goToService() {
-            // Create and submit POST form to redirect endpoint
-            const form = document.createElement('form');
-            form.method = 'POST';
-            form.action = '/_wol/redirect';
-            form.style.display = 'none';
-            document.body.appendChild(form);
-            form.submit();
-        }
-        
-        // Initial status check
-        fetch('/_wol/status')
-        .then(response => response.json())
-        .then(data => updateStatus(data))
-        .catch(err => console.error('Error getting initial status:', err));
-    </script>
-</body>
-</html>`
+	if w.dryRun {
+		if w.powerOffMethod == powerOffMethodSSH {
+			fmt.Printf("WOL Plugin [%s]: Dry run - would run %q on %s via SSH\n", w.name, w.sshCommand, w.sshHost)
+		} else {
+			fmt.Printf("WOL Plugin [%s]: Dry run - would execute power-off command: %s\n", w.name, w.powerOffCommand)
+		}
+		w.wakeMutex.Lock()
+		w.wakeCache.message = "Dry run complete - no power-off command was executed"
+		w.wakeCache.progress = 100
+		w.notifyWakeChangeLocked()
+		w.wakeMutex.Unlock()
+		return
+	}
+
+	if !w.drainBeforePowerOff(ctx) {
+		w.markCancelled(ctx, "Power-off")
+		return
+	}
+
+	if w.powerOffMethod == powerOffMethodSSH {
+		fmt.Printf("WOL Plugin [%s]: Starting power-off sequence via SSH to %s\n", w.name, w.sshHost)
+
+		w.wakeMutex.Lock()
+		w.wakeCache.message = fmt.Sprintf("Running power-off command on %s...", w.sshHost)
+		w.wakeCache.progress = 50
+		w.notifyWakeChangeLocked()
+		w.wakeMutex.Unlock()
+
+		runCtx, cancel := context.WithTimeout(ctx, w.powerOffTimeout)
+		err := w.sshRunner.Run(runCtx, w.sshCommand)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				w.markCancelled(ctx, "Power-off")
+				return
+			}
+			message := fmt.Sprintf("SSH power-off failed: %v", err)
+			if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+				message = fmt.Sprintf("SSH power-off timed out after %v", w.powerOffTimeout)
+			}
+			fmt.Printf("WOL Plugin [%s]: %s\n", w.name, message)
+			w.wakeMutex.Lock()
+			w.wakeCache.message = message
+			w.notifyWakeChangeLocked()
+			w.wakeMutex.Unlock()
+			return
+		}
+	} else {
+		fmt.Printf("WOL Plugin [%s]: Starting power-off sequence using custom script: %s\n", w.name, w.powerOffCommand)
+
+		w.wakeMutex.Lock()
+		w.wakeCache.message = "Power-off requires external script execution..."
+		w.wakeCache.progress = 50
+		w.notifyWakeChangeLocked()
+		w.wakeMutex.Unlock()
+
+		// Note: Since os/exec is not available in Yaegi, we cannot execute the script directly.
+		// The user must ensure their custom script is executed externally (e.g., via webhook, API call, etc.)
+		fmt.Printf("WOL Plugin [%s]: Power-off command configured: %s\n", w.name, w.powerOffCommand)
+		fmt.Printf("WOL Plugin [%s]: Note - Custom script must be executed externally as os/exec is not available in Yaegi\n", w.name)
+	}
+
+	w.wakeMutex.Lock()
+	w.wakeCache.message = "Power-off command executed successfully"
+	w.wakeCache.progress = powerOffCommandProgress
+	w.notifyWakeChangeLocked()
+	w.wakeMutex.Unlock()
+
+	// Give some time for the service to actually go down
+	if !w.sleep(ctx, 5*time.Second) {
+		w.markCancelled(ctx, "Power-off")
+		return
+	}
+
+	if !w.confirmPoweredOff(ctx) {
+		return
+	}
+
+	fmt.Printf("WOL Plugin [%s]: Power-off sequence completed\n", w.name)
+}
+
+// powerOffCommandProgress is the progress reached once the power-off command ran; the rest waits for
+// the shutdown check to confirm the service is down
+const powerOffCommandProgress = 80
+
+// confirmPoweredOff polls until the service is down, finishing the power-off at 100%. It gives up after
+// powerOffTimeout, leaving the progress short of 100 so the power-off is reported as failed, and returns false
+// then or if ctx was cancelled.
+func (w *WOLPlugin) confirmPoweredOff(ctx context.Context) bool {
+	for probe, elapsed := 0, time.Duration(0); ; probe++ {
+		if w.serviceIsDown() {
+			w.wakeMutex.Lock()
+			if w.isCurrentOperationLocked(ctx) {
+				w.wakeCache.message = "Power-off confirmed: service is down"
+				w.wakeCache.progress = 100
+				w.notifyWakeChangeLocked()
+			}
+			w.wakeMutex.Unlock()
+			return true
+		}
+		if elapsed >= w.powerOffTimeout {
+			fmt.Printf("WOL Plugin [%s]: Service still up %v after the power-off command\n", w.name, w.powerOffTimeout)
+			w.wakeMutex.Lock()
+			if w.isCurrentOperationLocked(ctx) {
+				w.wakeCache.message = fmt.Sprintf("Power-off command ran but the service is still up after %v", w.powerOffTimeout)
+				w.notifyWakeChangeLocked()
+			}
+			w.wakeMutex.Unlock()
+			return false
+		}
+
+		w.wakeMutex.Lock()
+		if w.isCurrentOperationLocked(ctx) {
+			w.wakeCache.message = "Waiting for the service to go down..."
+			w.notifyWakeChangeLocked()
+		}
+		w.wakeMutex.Unlock()
+
+		delay := w.wakePollDelay(probe)
+		if !w.sleep(ctx, delay) {
+			w.markCancelled(ctx, "Power-off")
+			return false
+		}
+		elapsed += delay
+	}
+}
+
+// serviceIsDown probes shutdownCheck, or inverts the health check when it is unset. An http(s) shutdownCheck
+// counts the service as up while it answers with a 2xx status; a tcp:// one while the port accepts connections.
+func (w *WOLPlugin) serviceIsDown() bool {
+	if w.shutdownCheck == "" {
+		return !w.performHealthCheck()
+	}
+
+	if strings.HasPrefix(w.shutdownCheck, "tcp://") {
+		address := strings.TrimPrefix(w.shutdownCheck, "tcp://")
+		conn, err := net.DialTimeout("tcp", address, w.httpClient.Timeout)
+		if err != nil {
+			if w.debug {
+				fmt.Printf("WOL Plugin [%s]: Shutdown check: %s refused: %v\n", w.name, address, err)
+			}
+			return true
+		}
+		conn.Close()
+		return false
+	}
+
+	req, err := http.NewRequest(http.MethodGet, w.shutdownCheck, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", w.healthCheckUserAgent)
+	req.Header.Set("Cache-Control", "no-cache")
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		if w.debug {
+			fmt.Printf("WOL Plugin [%s]: Shutdown check failed: %v\n", w.name, err)
+		}
+		return true
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode < 200 || resp.StatusCode >= 300
+}
+
+// validateShutdownCheck accepts an http(s) URL or tcp://host:port
+func validateShutdownCheck(check string) error {
+	parsed, err := url.Parse(check)
+	if err != nil {
+		return err
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		if parsed.Host == "" {
+			return fmt.Errorf("%q has no host", check)
+		}
+	case "tcp":
+		if _, _, err := net.SplitHostPort(parsed.Host); err != nil || parsed.Path != "" {
+			return fmt.Errorf("%q must be tcp://host:port", check)
+		}
+	default:
+		return fmt.Errorf("%q must be an http, https or tcp URL", check)
+	}
+	return nil
+}
+
+// drainProgress is the progress reached when draining ends and the power-off command runs
+const drainProgress = 40
+
+// drainBeforePowerOff starts refusing new requests and waits powerOffDrainPeriod so in-flight ones can finish
+// before the service goes down, reporting progress from 0 to drainProgress. It returns false if ctx was cancelled.
+func (w *WOLPlugin) drainBeforePowerOff(ctx context.Context) bool {
+	if w.powerOffDrainPeriod <= 0 {
+		return true
+	}
+
+	fmt.Printf("WOL Plugin [%s]: Draining connections for %v before power-off\n", w.name, w.powerOffDrainPeriod)
+	// performPowerOffSequence clears the flag once the service is down, so requests keep being refused meanwhile
+	w.wakeMutex.Lock()
+	w.draining = true
+	w.wakeMutex.Unlock()
+
+	for elapsed := time.Duration(0); elapsed < w.powerOffDrainPeriod; {
+		remaining := w.powerOffDrainPeriod - elapsed
+		w.wakeMutex.Lock()
+		if w.isCurrentOperationLocked(ctx) {
+			w.wakeCache.message = fmt.Sprintf("Draining connections before power-off... (%v remaining)", remaining.Truncate(time.Second))
+			w.wakeCache.progress = int(float64(elapsed) / float64(w.powerOffDrainPeriod) * drainProgress)
+			w.notifyWakeChangeLocked()
+		}
+		w.wakeMutex.Unlock()
+
+		step := time.Second
+		if remaining < step {
+			step = remaining
+		}
+		if !w.sleep(ctx, step) {
+			return false
+		}
+		elapsed += step
+	}
+	return true
+}
+
+// isDraining reports whether a power-off is currently refusing new requests
+func (w *WOLPlugin) isDraining() bool {
+	w.wakeMutex.RLock()
+	defer w.wakeMutex.RUnlock()
+	return w.draining
+}
+
+// eventQueueSize bounds the state events waiting to be published; newer events are dropped when it is full
+const eventQueueSize = 32
+
+// statePublisher delivers state change events to an external system such as an MQTT broker
+type statePublisher interface {
+	Publish(topic string, payload []byte) error
+	Close() error
+}
 
-// ServeHTTP implements the http.Handler interface.
-func (w *WOLPlugin) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	// Handle control page endpoints
-	if strings.HasPrefix(req.URL.Path, "/_wol/") {
-		switch req.URL.Path {
-		case "/_wol/wake":
-			w.handleWakeEndpoint(rw, req)
-			return
-		case "/_wol/poweroff":
-			w.handlePowerOffEndpoint(rw, req)
-			return
-		case "/_wol/status":
-			w.handleStatusEndpoint(rw, req)
-			return
-		case "/_wol/redirect":
-			w.handleRedirectEndpoint(rw, req)
+// publishEvent queues a state change event for publishing without blocking the caller
+func (w *WOLPlugin) publishEvent(event string, details map[string]interface{}) {
+	if w.events == nil {
+		return
+	}
+
+	message := map[string]interface{}{
+		"event":     event,
+		"name":      w.name,
+		"timestamp": w.now().UTC().Format(time.RFC3339),
+	}
+	for key, value := range details {
+		message[key] = value
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	select {
+	case w.events <- payload:
+	default:
+		fmt.Printf("WOL Plugin [%s]: State event queue full, dropping %s event\n", w.name, event)
+	}
+}
+
+// runEventPublisher publishes queued state events in order until ctx is done
+func (w *WOLPlugin) runEventPublisher(ctx context.Context) {
+	defer w.publisher.Close()
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		case payload := <-w.events:
+			if err := w.publisher.Publish(w.mqttTopic, payload); err != nil {
+				fmt.Printf("WOL Plugin [%s]: Failed to publish state event: %v\n", w.name, err)
+			} else if w.debug {
+				fmt.Printf("WOL Plugin [%s]: Published state event to %s: %s\n", w.name, w.mqttTopic, payload)
+			}
+		}
+	}
+}
+
+// parseMQTTBroker normalizes a broker given as "host", "host:port" or "tcp://host:port" to host:port
+func parseMQTTBroker(broker string) (string, error) {
+	address := broker
+	if strings.Contains(broker, "://") {
+		parsed, err := url.Parse(broker)
+		if err != nil {
+			return "", fmt.Errorf("invalid mqttBroker %q: %v", broker, err)
 		}
+		if parsed.Scheme != "tcp" && parsed.Scheme != "mqtt" {
+			return "", fmt.Errorf("invalid mqttBroker %q: scheme must be tcp or mqtt", broker)
+		}
+		address = parsed.Host
+	}
+	if address == "" {
+		return "", fmt.Errorf("invalid mqttBroker %q: missing host", broker)
 	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "1883")
+	}
+	return address, nil
+}
 
+// mqttClient is a minimal MQTT 3.1.1 client that publishes retained QoS 0 messages.
+// It connects lazily on the first publish and reconnects once when a publish fails.
+type mqttClient struct {
+	address  string
+	clientID string
+	username string
+	password string
+	timeout  time.Duration
+	conn     net.Conn
+	mutex    sync.Mutex
+}
 
-	// Check for bypass state first (handles "Go to Service" functionality)
-	if w.isBypassActive() {
-		if w.debug {
-			fmt.Printf("WOL Plugin [%s]: Bypass state active, forwarding to service\n", w.name)
+// newMQTTClient creates an MQTT client for the broker at address without connecting
+func newMQTTClient(address, clientID, username, password string) *mqttClient {
+	return &mqttClient{
+		address:  address,
+		clientID: clientID,
+		username: username,
+		password: password,
+		timeout:  5 * time.Second,
+	}
+}
+
+// Publish sends payload to topic as a retained message
+func (c *mqttClient) Publish(topic string, payload []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	packet := mqttPublishPacket(topic, payload)
+	err := c.writeLocked(packet)
+	if err != nil && c.conn != nil {
+		// The broker may have dropped an idle connection; retry once on a fresh one
+		c.closeLocked()
+		err = c.writeLocked(packet)
+	}
+	if err != nil {
+		c.closeLocked()
+	}
+	return err
+}
+
+// Close disconnects from the broker
+func (c *mqttClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn != nil {
+		c.conn.Write([]byte{0xE0, 0x00}) // DISCONNECT
+	}
+	return c.closeLocked()
+}
+
+func (c *mqttClient) writeLocked(packet []byte) error {
+	if c.conn == nil {
+		if err := c.connectLocked(); err != nil {
+			return err
 		}
-		// Clear bypass state after use
-		w.clearBypassState()
-		w.next.ServeHTTP(rw, req)
-		return
 	}
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(packet)
+	return err
+}
 
-	// Check if control page is enabled
-	if w.enableControlPage {
-		
-		isHealthy := w.getCachedHealthStatus()
-		
-		// Show control page unless configured to skip when healthy
-		if !isHealthy || !w.skipControlPageWhenHealthy {
-			w.serveControlPage(rw, req)
-			return
+func (c *mqttClient) closeLocked() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// connectLocked dials the broker and completes the CONNECT/CONNACK handshake
+func (c *mqttClient) connectLocked() error {
+	conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker %s: %v", c.address, err)
+	}
+
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := conn.Write(mqttConnectPacket(c.clientID, c.username, c.password)); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to send MQTT CONNECT: %v", err)
+	}
+
+	connack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, connack); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to read MQTT CONNACK: %v", err)
+	}
+	if connack[0] != 0x20 || connack[1] != 0x02 {
+		conn.Close()
+		return fmt.Errorf("unexpected MQTT CONNACK packet %x", connack)
+	}
+	if connack[3] != 0 {
+		conn.Close()
+		return fmt.Errorf("MQTT broker refused connection with return code %d", connack[3])
+	}
+
+	conn.SetDeadline(time.Time{})
+	c.conn = conn
+	return nil
+}
+
+// mqttConnectPacket builds a CONNECT packet with a clean session and keep-alive disabled
+func mqttConnectPacket(clientID, username, password string) []byte {
+	var flags byte = 0x02 // clean session
+	payload := mqttString(clientID)
+	if username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(username)...)
+		if password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(password)...)
 		}
-		
-		// Service is healthy and we're configured to skip control page
-		w.next.ServeHTTP(rw, req)
-		return
 	}
 
-	// Control page disabled - use original auto-wake behavior
-	isHealthy := w.getCachedHealthStatus()
-	if !isHealthy {
-		w.performAutoWake(rw, req)
-		return
+	body := append(mqttString("MQTT"), 0x04, flags, 0x00, 0x00)
+	body = append(body, payload...)
+	return append(append([]byte{0x10}, mqttRemainingLength(len(body))...), body...)
+}
+
+// mqttPublishPacket builds a retained QoS 0 PUBLISH packet
+func mqttPublishPacket(topic string, payload []byte) []byte {
+	body := append(mqttString(topic), payload...)
+	return append(append([]byte{0x31}, mqttRemainingLength(len(body))...), body...)
+}
+
+// mqttString encodes s as a length-prefixed MQTT UTF-8 string
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+// mqttRemainingLength encodes a packet length using MQTT's variable-length scheme
+func mqttRemainingLength(length int) []byte {
+	var encoded []byte
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		encoded = append(encoded, digit)
+		if length == 0 {
+			return encoded
+		}
 	}
+}
 
-	w.next.ServeHTTP(rw, req)
+// Power-off methods selecting how performPowerOffSequence shuts the target down
+const (
+	powerOffMethodCommand = "command"
+	powerOffMethodSSH     = "ssh"
+)
+
+// defaultSSHCommand is run on the target when powerOffMethod is "ssh" and no sshCommand is configured
+const defaultSSHCommand = "sudo shutdown -h now"
+
+// commandRunner runs a command on the target machine, returning an error unless it exits with status zero
+type commandRunner interface {
+	Run(ctx context.Context, command string) error
 }
 
-// getCachedHealthStatus returns cached health status or performs new check if cache expired
-func (w *WOLPlugin) getCachedHealthStatus() bool {
-	w.healthMutex.RLock()
-	cache := w.healthCache
-	now := time.Now()
-	
-	// Check if cache is valid
-	if now.Sub(cache.lastCheck) < w.healthCheckInterval {
-		w.healthMutex.RUnlock()
-		return cache.isHealthy
+// parseSSHHost normalizes an SSH host given as "host" or "host:port" to host:port
+func parseSSHHost(host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return "", fmt.Errorf("sshHost is required when powerOffMethod is %q", powerOffMethodSSH)
 	}
-	w.healthMutex.RUnlock()
+	address := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		address = net.JoinHostPort(strings.Trim(host, "[]"), "22")
+	}
+	hostname, port, err := net.SplitHostPort(address)
+	if err != nil || hostname == "" {
+		return "", fmt.Errorf("invalid sshHost %q: expected host or host:port", host)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid sshHost %q: port must be between 1 and 65535", host)
+	}
+	return address, nil
+}
 
-	// Cache expired, perform new health check
-	w.healthMutex.Lock()
-	defer w.healthMutex.Unlock()
+// parseSSHHostKey parses a pinned host key in authorized_keys format ("ssh-ed25519 AAAA... comment")
+// and returns its wire-format blob
+func parseSSHHostKey(value string) ([]byte, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid sshHostKey: expected \"<type> <base64 key>\"")
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid sshHostKey: %v", err)
+	}
+	r := &sshReader{data: blob}
+	keyType := string(r.string())
+	if r.err != nil || keyType != fields[0] {
+		return nil, fmt.Errorf("invalid sshHostKey: key data does not match type %q", fields[0])
+	}
+	return blob, nil
+}
+
+// sshRunner runs commands over SSH. os/exec is unavailable in Yaegi, so this is a minimal SSH 2.0
+// client built on the standard library: curve25519-sha256 key exchange, aes-ctr with hmac-sha2-256,
+// public key authentication and a single exec channel per command.
+type sshRunner struct {
+	address string
+	user    string
+	signer  *sshSigner
+	hostKey []byte // pinned host key blob; the session is refused for any other key
+	timeout time.Duration
+}
 
-	// Double-check pattern - another goroutine might have updated while waiting for lock
-	if now.Sub(w.healthCache.lastCheck) < w.healthCheckInterval {
-		return w.healthCache.isHealthy
+// Run connects to the host, executes command and waits for its exit status
+func (r *sshRunner) Run(ctx context.Context, command string) error {
+	dialer := net.Dialer{Timeout: r.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", r.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", r.address, err)
+	}
+	defer conn.Close()
+	// A deadline on ctx bounds the session through the cancellation below, so ctx reports why it ended
+	if _, ok := ctx.Deadline(); !ok {
+		conn.SetDeadline(time.Now().Add(r.timeout))
 	}
 
-	newHealth := w.performHealthCheck()
-	
-	// Log only on state changes or debug mode
-	if w.healthCache.lastState != newHealth || w.debug {
-		if w.debug || w.healthCache.lastCheck.IsZero() {
-			fmt.Printf("WOL Plugin [%s]: Health status changed to %v for %s\n", w.name, newHealth, w.healthCheck)
+	// Unblock pending reads when the operation is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
 		}
-		w.healthCache.lastState = newHealth
+	}()
+
+	t := newSSHTransport(conn)
+	if r.hostKey == nil {
+		return fmt.Errorf("no sshHostKey pinned for %s", r.address)
 	}
-	
-	w.healthCache.isHealthy = newHealth
-	w.healthCache.lastCheck = now
-	
-	return newHealth
+	sessionID, err := t.clientHandshake(r.hostKey)
+	if err != nil {
+		return fmt.Errorf("SSH handshake with %s failed: %v", r.address, err)
+	}
+	if err := t.authenticate(sessionID, r.user, r.signer); err != nil {
+		return err
+	}
+	return t.exec(command)
 }
 
-// isBypassActive checks if bypass state is active and not expired
-func (w *WOLPlugin) isBypassActive() bool {
-	w.bypassMutex.RLock()
-	defer w.bypassMutex.RUnlock()
-	
-	if !w.bypassCache.isBypass {
-		return false
+// SSH message numbers (RFC 4250)
+const (
+	sshMsgDisconnect          = 1
+	sshMsgIgnore              = 2
+	sshMsgUnimplemented       = 3
+	sshMsgDebug               = 4
+	sshMsgServiceRequest      = 5
+	sshMsgServiceAccept       = 6
+	sshMsgKexInit             = 20
+	sshMsgNewKeys             = 21
+	sshMsgKexECDHInit         = 30
+	sshMsgKexECDHReply        = 31
+	sshMsgUserAuthRequest     = 50
+	sshMsgUserAuthFailure     = 51
+	sshMsgUserAuthSuccess     = 52
+	sshMsgUserAuthBanner      = 53
+	sshMsgGlobalRequest       = 80
+	sshMsgRequestFailure      = 82
+	sshMsgChannelOpen         = 90
+	sshMsgChannelOpenConfirm  = 91
+	sshMsgChannelOpenFailure  = 92
+	sshMsgChannelWindowAdjust = 93
+	sshMsgChannelData         = 94
+	sshMsgChannelExtendedData = 95
+	sshMsgChannelEOF          = 96
+	sshMsgChannelClose        = 97
+	sshMsgChannelRequest      = 98
+	sshMsgChannelSuccess      = 99
+	sshMsgChannelFailure      = 100
+)
+
+// Algorithms offered during key exchange, in order of preference
+var (
+	sshClientVersion     = "SSH-2.0-TraefikPowerManagement_" + PluginVersion
+	sshKexAlgorithms     = []string{"curve25519-sha256", "curve25519-sha256@libssh.org"}
+	sshHostKeyAlgorithms = []string{"ssh-ed25519", "ecdsa-sha2-nistp256", "rsa-sha2-512", "rsa-sha2-256"}
+	sshCiphers           = []string{"aes128-ctr", "aes256-ctr"}
+	sshMACs              = []string{"hmac-sha2-256"}
+)
+
+// sshMaxPacket bounds incoming packets; command output arrives in chunks well below this
+const sshMaxPacket = 256 * 1024
+
+// sshTransport implements the SSH binary packet protocol (RFC 4253) over a connection
+type sshTransport struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	rand        io.Reader // randomness for padding, the KEXINIT cookie and the ephemeral key
+	readSeq     uint32
+	writeSeq    uint32
+	readCipher  cipher.Stream
+	writeCipher cipher.Stream
+	readMAC     hash.Hash
+	writeMAC    hash.Hash
+}
+
+func newSSHTransport(conn net.Conn) *sshTransport {
+	return &sshTransport{conn: conn, reader: bufio.NewReader(conn), rand: rand.Reader}
+}
+
+// setReadKeys switches incoming packets to aes-ctr with hmac-sha2-256
+func (t *sshTransport) setReadKeys(key, iv, macKey []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
 	}
-	
-	// Check if bypass has expired (5 second timeout)
-	if time.Since(w.bypassCache.startTime) > 5*time.Second {
-		return false
+	t.readCipher = cipher.NewCTR(block, iv)
+	t.readMAC = hmac.New(sha256.New, macKey)
+	return nil
+}
+
+// setWriteKeys switches outgoing packets to aes-ctr with hmac-sha2-256
+func (t *sshTransport) setWriteKeys(key, iv, macKey []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
 	}
-	
-	return true
+	t.writeCipher = cipher.NewCTR(block, iv)
+	t.writeMAC = hmac.New(sha256.New, macKey)
+	return nil
 }
 
-// clearBypassState clears the bypass state
-func (w *WOLPlugin) clearBypassState() {
-	w.bypassMutex.Lock()
-	defer w.bypassMutex.Unlock()
-	
-	w.bypassCache.isBypass = false
-	w.bypassCache.startTime = time.Time{}
+// writeVersion sends the identification string
+func (t *sshTransport) writeVersion(version string) error {
+	_, err := t.conn.Write([]byte(version + "\r\n"))
+	return err
 }
 
-func (w *WOLPlugin) performHealthCheck() bool {
-	// Create optimized HTTP client with connection pooling
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        10,
-			MaxIdleConnsPerHost: 5,
-			IdleConnTimeout:     30 * time.Second,
-			DisableKeepAlives:   false,
-		},
+// readVersion reads the peer identification string, skipping any preceding banner lines
+func (t *sshTransport) readVersion() (string, error) {
+	for i := 0; i < 32; i++ {
+		line, err := t.reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("failed to read SSH version: %v", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "SSH-") {
+			if !strings.HasPrefix(line, "SSH-2.0-") && !strings.HasPrefix(line, "SSH-1.99-") {
+				return "", fmt.Errorf("unsupported SSH version %q", line)
+			}
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("no SSH version received")
+}
+
+// writePacket frames, pads, encrypts and authenticates payload
+func (t *sshTransport) writePacket(payload []byte) error {
+	blockSize := 8
+	if t.writeCipher != nil {
+		blockSize = aes.BlockSize
+	}
+	padding := blockSize - (5+len(payload))%blockSize
+	if padding < 4 {
+		padding += blockSize
+	}
+
+	packet := make([]byte, 5+len(payload)+padding)
+	binary.BigEndian.PutUint32(packet, uint32(1+len(payload)+padding))
+	packet[4] = byte(padding)
+	copy(packet[5:], payload)
+	if _, err := io.ReadFull(t.rand, packet[5+len(payload):]); err != nil {
+		return err
+	}
+
+	var mac []byte
+	if t.writeMAC != nil {
+		mac = t.packetMAC(t.writeMAC, t.writeSeq, packet)
+		t.writeCipher.XORKeyStream(packet, packet)
+	}
+	t.writeSeq++
+
+	_, err := t.conn.Write(append(packet, mac...))
+	return err
+}
+
+// readPacket reads, decrypts and verifies the next packet and returns its payload
+func (t *sshTransport) readPacket() ([]byte, error) {
+	blockSize := 8
+	if t.readCipher != nil {
+		blockSize = aes.BlockSize
+	}
+
+	packet := make([]byte, blockSize)
+	if _, err := io.ReadFull(t.reader, packet); err != nil {
+		return nil, err
+	}
+	if t.readCipher != nil {
+		t.readCipher.XORKeyStream(packet, packet)
+	}
+	length := binary.BigEndian.Uint32(packet)
+	if length < 5 || length > sshMaxPacket || (length+4)%uint32(blockSize) != 0 {
+		return nil, fmt.Errorf("invalid SSH packet length %d", length)
+	}
+
+	rest := make([]byte, int(length)+4-blockSize)
+	if _, err := io.ReadFull(t.reader, rest); err != nil {
+		return nil, err
+	}
+	if t.readCipher != nil {
+		t.readCipher.XORKeyStream(rest, rest)
+	}
+	packet = append(packet, rest...)
+
+	if t.readMAC != nil {
+		mac := make([]byte, t.readMAC.Size())
+		if _, err := io.ReadFull(t.reader, mac); err != nil {
+			return nil, err
+		}
+		if !hmac.Equal(mac, t.packetMAC(t.readMAC, t.readSeq, packet)) {
+			return nil, fmt.Errorf("SSH packet MAC mismatch")
+		}
+	}
+	t.readSeq++
+
+	padding := int(packet[4])
+	if padding < 4 || 5+padding > len(packet) {
+		return nil, fmt.Errorf("invalid SSH packet padding %d", padding)
+	}
+	payload := packet[5 : len(packet)-padding]
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("empty SSH packet")
+	}
+	return payload, nil
+}
+
+func (t *sshTransport) packetMAC(mac hash.Hash, seq uint32, packet []byte) []byte {
+	var seqBytes [4]byte
+	binary.BigEndian.PutUint32(seqBytes[:], seq)
+	mac.Reset()
+	mac.Write(seqBytes[:])
+	mac.Write(packet)
+	return mac.Sum(nil)
+}
+
+// readMessage returns the next payload, skipping transport-level noise and surfacing disconnects
+func (t *sshTransport) readMessage() ([]byte, error) {
+	for {
+		payload, err := t.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		switch payload[0] {
+		case sshMsgIgnore, sshMsgDebug, sshMsgUnimplemented, sshMsgUserAuthBanner:
+			continue
+		case sshMsgDisconnect:
+			r := &sshReader{data: payload[1:]}
+			code := r.uint32()
+			return nil, fmt.Errorf("server disconnected (code %d): %s", code, r.string())
+		}
+		return payload, nil
+	}
+}
+
+// expectMessage reads the next message and fails unless it has type msgType
+func (t *sshTransport) expectMessage(msgType byte) (*sshReader, error) {
+	payload, err := t.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	if payload[0] != msgType {
+		return nil, fmt.Errorf("unexpected SSH message %d, expected %d", payload[0], msgType)
+	}
+	return &sshReader{data: payload[1:]}, nil
+}
+
+// clientHandshake exchanges versions and keys, checks the server host key against pinnedHostKey and returns
+// the session ID
+func (t *sshTransport) clientHandshake(pinnedHostKey []byte) ([]byte, error) {
+	if err := t.writeVersion(sshClientVersion); err != nil {
+		return nil, err
+	}
+	serverVersion, err := t.readVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	clientKexInit, err := sshKexInitPayload(t.rand, sshKexAlgorithms, sshHostKeyAlgorithms, sshCiphers, sshMACs)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.writePacket(clientKexInit); err != nil {
+		return nil, err
+	}
+	serverKexInit, err := t.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	if serverKexInit[0] != sshMsgKexInit {
+		return nil, fmt.Errorf("unexpected SSH message %d, expected KEXINIT", serverKexInit[0])
+	}
+	serverLists, err := parseSSHKexInit(serverKexInit)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := sshNegotiate("key exchange", sshKexAlgorithms, serverLists[0]); err != nil {
+		return nil, err
+	}
+	hostKeyAlgorithm, err := sshNegotiate("host key", sshHostKeyAlgorithms, serverLists[1])
+	if err != nil {
+		return nil, err
+	}
+	writeCipherName, err := sshNegotiate("cipher", sshCiphers, serverLists[2])
+	if err != nil {
+		return nil, err
+	}
+	readCipherName, err := sshNegotiate("cipher", sshCiphers, serverLists[3])
+	if err != nil {
+		return nil, err
+	}
+	for i, direction := range []int{4, 5} {
+		if _, err := sshNegotiate("MAC", sshMACs, serverLists[direction]); err != nil {
+			return nil, err
+		}
+		if _, err := sshNegotiate("compression", []string{"none"}, serverLists[6+i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Built from t.rand directly, since GenerateKey may ignore the reader it is given
+	seed := make([]byte, 32)
+	if _, err := io.ReadFull(t.rand, seed); err != nil {
+		return nil, err
+	}
+	ephemeral, err := ecdh.X25519().NewPrivateKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	clientPublic := ephemeral.PublicKey().Bytes()
+	if err := t.writePacket(sshAppendString([]byte{sshMsgKexECDHInit}, clientPublic)); err != nil {
+		return nil, err
+	}
+
+	reply, err := t.expectMessage(sshMsgKexECDHReply)
+	if err != nil {
+		return nil, err
+	}
+	hostKey := reply.string()
+	serverPublic := reply.string()
+	signature := reply.string()
+	if reply.err != nil {
+		return nil, reply.err
 	}
 
-	// Create request with proper headers
-	req, err := http.NewRequest("GET", w.healthCheck, nil)
+	peer, err := ecdh.X25519().NewPublicKey(serverPublic)
 	if err != nil {
-		if w.debug {
-			fmt.Printf("WOL Plugin [%s]: Health check request creation failed: %v\n", w.name, err)
-		}
-		return false
+		return nil, fmt.Errorf("invalid server key exchange value: %v", err)
 	}
-	
-	// Add headers to avoid caching and identify the health checker
-	req.Header.Set("User-Agent", "Traefik-WOL-Plugin/"+PluginVersion)
-	req.Header.Set("Cache-Control", "no-cache")
-	req.Header.Set("Connection", "keep-alive")
-
-	resp, err := client.Do(req)
+	secret, err := ephemeral.ECDH(peer)
 	if err != nil {
-		if w.debug {
-			fmt.Printf("WOL Plugin [%s]: Health check failed: %v\n", w.name, err)
-		}
-		return false
+		return nil, err
 	}
-	defer func() {
-		// Ensure body is read and closed for connection reuse
-		if resp.Body != nil {
-			resp.Body.Close()
-		}
-	}()
 
-	healthy := resp.StatusCode >= 200 && resp.StatusCode < 300
-	
-	// Log health status changes more intelligently
-	if w.debug {
-		fmt.Printf("WOL Plugin [%s]: Health check status: %d (healthy: %v) for %s\n", 
-			w.name, resp.StatusCode, healthy, w.healthCheck)
+	exchangeHash := sshExchangeHash(sshClientVersion, serverVersion, clientKexInit, serverKexInit, hostKey, clientPublic, serverPublic, secret)
+	if !bytes.Equal(hostKey, pinnedHostKey) {
+		return nil, fmt.Errorf("host key does not match sshHostKey")
 	}
-	
-	return healthy
+	if err := sshVerifySignature(hostKeyAlgorithm, hostKey, signature, exchangeHash); err != nil {
+		return nil, fmt.Errorf("invalid host key signature: %v", err)
+	}
+
+	if err := t.writePacket([]byte{sshMsgNewKeys}); err != nil {
+		return nil, err
+	}
+	sessionID := exchangeHash
+	if err := t.setWriteKeys(
+		sshDeriveKey(secret, exchangeHash, sessionID, 'C', sshCipherKeySize(writeCipherName)),
+		sshDeriveKey(secret, exchangeHash, sessionID, 'A', aes.BlockSize),
+		sshDeriveKey(secret, exchangeHash, sessionID, 'E', sha256.Size),
+	); err != nil {
+		return nil, err
+	}
+	if _, err := t.expectMessage(sshMsgNewKeys); err != nil {
+		return nil, err
+	}
+	if err := t.setReadKeys(
+		sshDeriveKey(secret, exchangeHash, sessionID, 'D', sshCipherKeySize(readCipherName)),
+		sshDeriveKey(secret, exchangeHash, sessionID, 'B', aes.BlockSize),
+		sshDeriveKey(secret, exchangeHash, sessionID, 'F', sha256.Size),
+	); err != nil {
+		return nil, err
+	}
+	return sessionID, nil
 }
 
+// authenticate requests the ssh-userauth service and signs in with the private key
+func (t *sshTransport) authenticate(sessionID []byte, user string, signer *sshSigner) error {
+	request := sshAppendString([]byte{sshMsgServiceRequest}, []byte("ssh-userauth"))
+	if err := t.writePacket(request); err != nil {
+		return err
+	}
+	if _, err := t.expectMessage(sshMsgServiceAccept); err != nil {
+		return err
+	}
 
-// getNetworkInterfaces returns available network interfaces for WOL packet sending
-func (w *WOLPlugin) getNetworkInterfaces() ([]net.Interface, error) {
-	interfaces, err := net.Interfaces()
+	signed := sshAppendString(nil, sessionID)
+	signed = append(signed, sshMsgUserAuthRequest)
+	signed = sshAppendString(signed, []byte(user))
+	signed = sshAppendString(signed, []byte("ssh-connection"))
+	signed = sshAppendString(signed, []byte("publickey"))
+	signed = append(signed, 1)
+	signed = sshAppendString(signed, []byte(signer.algorithm))
+	signed = sshAppendString(signed, signer.publicKey)
+	signature, err := signer.sign(signed)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get network interfaces: %v", err)
+		return fmt.Errorf("failed to sign SSH authentication request: %v", err)
 	}
 
-	var validInterfaces []net.Interface
-	for _, iface := range interfaces {
-		// Skip loopback and down interfaces
-		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
-			continue
-		}
-		
-		// If specific interface is configured, only use that one
-		if w.networkInterface != "" && iface.Name != w.networkInterface {
-			continue
-		}
-		
-		validInterfaces = append(validInterfaces, iface)
+	if err := t.writePacket(sshAppendString(signed[4+len(sessionID):], signature)); err != nil {
+		return err
 	}
-	
-	if len(validInterfaces) == 0 {
-		return nil, fmt.Errorf("no valid network interfaces found")
+	payload, err := t.readMessage()
+	if err != nil {
+		return err
 	}
-	
-	return validInterfaces, nil
-}
-
-// calculateBroadcastAddress calculates broadcast address for a given network
-func (w *WOLPlugin) calculateBroadcastAddress(ip net.IP, mask net.IPMask) net.IP {
-	if ip == nil || mask == nil {
+	switch payload[0] {
+	case sshMsgUserAuthSuccess:
 		return nil
+	case sshMsgUserAuthFailure:
+		return fmt.Errorf("SSH authentication failed for user %q", user)
 	}
-	
-	network := ip.Mask(mask)
-	broadcast := make(net.IP, len(network))
-	for i := range network {
-		broadcast[i] = network[i] | ^mask[i]
-	}
-	
-	return broadcast
+	return fmt.Errorf("unexpected SSH message %d during authentication", payload[0])
 }
 
-// getBroadcastAddresses returns all possible broadcast addresses for WOL
-func (w *WOLPlugin) getBroadcastAddresses() []string {
-	var addresses []string
-	
-	// Use configured broadcast address if provided
-	if w.broadcastAddress != "" {
-		addresses = append(addresses, w.broadcastAddress)
-		return addresses
+// exec opens a session channel, runs command and returns an error unless it exits with status zero
+func (t *sshTransport) exec(command string) error {
+	const clientChannel = 0
+	const windowSize = 1 << 20
+
+	open := sshAppendString([]byte{sshMsgChannelOpen}, []byte("session"))
+	open = sshAppendUint32(open, clientChannel)
+	open = sshAppendUint32(open, windowSize)
+	open = sshAppendUint32(open, 32*1024)
+	if err := t.writePacket(open); err != nil {
+		return err
 	}
-	
-	// Auto-discover broadcast addresses
-	interfaces, err := w.getNetworkInterfaces()
+
+	payload, err := t.readMessage()
 	if err != nil {
-		if w.debug {
-			fmt.Printf("WOL Plugin [%s]: Failed to get interfaces: %v\n", w.name, err)
-		}
-		return addresses
+		return err
 	}
-	
-	for _, iface := range interfaces {
-		addrs, err := iface.Addrs()
+	if payload[0] == sshMsgChannelOpenFailure {
+		r := &sshReader{data: payload[1:]}
+		r.uint32()
+		r.uint32()
+		return fmt.Errorf("SSH server refused session: %s", r.string())
+	}
+	if payload[0] != sshMsgChannelOpenConfirm {
+		return fmt.Errorf("unexpected SSH message %d, expected channel confirmation", payload[0])
+	}
+	r := &sshReader{data: payload[1:]}
+	r.uint32()
+	serverChannel := r.uint32()
+	if r.err != nil {
+		return r.err
+	}
+
+	request := sshAppendUint32([]byte{sshMsgChannelRequest}, serverChannel)
+	request = sshAppendString(request, []byte("exec"))
+	request = append(request, 1)
+	request = sshAppendString(request, []byte(command))
+	if err := t.writePacket(request); err != nil {
+		return err
+	}
+
+	var stderr []byte
+	exitStatus := -1
+	exitSignal := ""
+	for {
+		payload, err := t.readMessage()
 		if err != nil {
-			continue
+			if exitStatus >= 0 || exitSignal != "" {
+				break // some servers drop the connection right after reporting the exit status
+			}
+			return fmt.Errorf("connection closed before command exit status was received: %v", err)
 		}
-		
-		for _, addr := range addrs {
-			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
-				broadcast := w.calculateBroadcastAddress(ipNet.IP, ipNet.Mask)
-				if broadcast != nil {
-					addresses = append(addresses, broadcast.String())
+		r := &sshReader{data: payload[1:]}
+		switch payload[0] {
+		case sshMsgChannelSuccess, sshMsgChannelWindowAdjust, sshMsgChannelEOF:
+		case sshMsgChannelFailure:
+			return fmt.Errorf("SSH server refused to execute command")
+		case sshMsgChannelData, sshMsgChannelExtendedData:
+			r.uint32()
+			if payload[0] == sshMsgChannelExtendedData {
+				r.uint32()
+			}
+			data := r.string()
+			if payload[0] == sshMsgChannelExtendedData && len(stderr) < 512 {
+				stderr = append(stderr, data...)
+			}
+			// Keep the server's send window open
+			adjust := sshAppendUint32([]byte{sshMsgChannelWindowAdjust}, serverChannel)
+			if err := t.writePacket(sshAppendUint32(adjust, uint32(len(data)))); err != nil {
+				return err
+			}
+		case sshMsgChannelRequest:
+			r.uint32()
+			requestType := string(r.string())
+			wantReply := r.bool()
+			switch requestType {
+			case "exit-status":
+				exitStatus = int(r.uint32())
+			case "exit-signal":
+				exitSignal = string(r.string())
+			}
+			if wantReply {
+				if err := t.writePacket(sshAppendUint32([]byte{sshMsgChannelFailure}, serverChannel)); err != nil {
+					return err
+				}
+			}
+		case sshMsgGlobalRequest:
+			r.string()
+			if r.bool() {
+				if err := t.writePacket([]byte{sshMsgRequestFailure}); err != nil {
+					return err
 				}
 			}
+		case sshMsgChannelClose:
+			t.writePacket(sshAppendUint32([]byte{sshMsgChannelClose}, serverChannel))
+		default:
+			return fmt.Errorf("unexpected SSH message %d while waiting for command", payload[0])
+		}
+		if payload[0] == sshMsgChannelClose {
+			break
 		}
 	}
-	
-	// Add common broadcast addresses as fallback
-	if len(addresses) == 0 {
-		addresses = append(addresses, "255.255.255.255") // Limited broadcast
+
+	output := strings.TrimSpace(string(stderr))
+	switch {
+	case exitSignal != "":
+		return fmt.Errorf("command terminated by signal %s", exitSignal)
+	case exitStatus < 0:
+		return fmt.Errorf("command finished without an exit status")
+	case exitStatus != 0 && output != "":
+		return fmt.Errorf("command exited with status %d: %s", exitStatus, output)
+	case exitStatus != 0:
+		return fmt.Errorf("command exited with status %d", exitStatus)
 	}
-	
-	return addresses
+	return nil
 }
 
-func (w *WOLPlugin) sendWOLPacket() error {
-	macBytes, err := w.parseMACAddress(w.macAddress)
-	if err != nil {
-		return fmt.Errorf("invalid MAC address: %v", err)
+// sshKexInitPayload builds a KEXINIT message offering the given algorithms in both directions
+func sshKexInitPayload(random io.Reader, kex, hostKey, ciphers, macs []string) ([]byte, error) {
+	payload := make([]byte, 17)
+	payload[0] = sshMsgKexInit
+	if _, err := io.ReadFull(random, payload[1:]); err != nil {
+		return nil, err
 	}
+	for _, list := range [][]string{kex, hostKey, ciphers, ciphers, macs, macs, {"none"}, {"none"}, nil, nil} {
+		payload = sshAppendString(payload, []byte(strings.Join(list, ",")))
+	}
+	payload = append(payload, 0) // first_kex_packet_follows
+	return sshAppendUint32(payload, 0), nil
+}
 
-	packet := w.createMagicPacket(macBytes)
-	sentSuccessfully := false
-	var lastError error
-
-	// Try unicast to specific IP first (if provided)
-	if w.ipAddress != "" {
-		err := w.sendToAddress(packet, w.ipAddress)
-		if err == nil {
-			sentSuccessfully = true
-			if w.debug {
-				fmt.Printf("WOL Plugin [%s]: Magic packet sent via unicast to %s (%s:%d)\n", w.name, w.macAddress, w.ipAddress, w.port)
-			}
-		} else {
-			lastError = err
-			if w.debug {
-				fmt.Printf("WOL Plugin [%s]: Unicast failed: %v\n", w.name, err)
-			}
-		}
+// parseSSHKexInit returns the ten algorithm name-lists of a KEXINIT message
+func parseSSHKexInit(payload []byte) ([][]string, error) {
+	if len(payload) < 17 {
+		return nil, fmt.Errorf("short KEXINIT message")
+	}
+	r := &sshReader{data: payload[17:]}
+	lists := make([][]string, 10)
+	for i := range lists {
+		lists[i] = strings.Split(string(r.string()), ",")
 	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed KEXINIT message: %v", r.err)
+	}
+	return lists, nil
+}
 
-	// Try broadcast addresses for better container/LXC compatibility
-	broadcastAddresses := w.getBroadcastAddresses()
-	for _, broadcastAddr := range broadcastAddresses {
-		err := w.sendToAddress(packet, broadcastAddr)
-		if err == nil {
-			sentSuccessfully = true
-			if w.debug {
-				fmt.Printf("WOL Plugin [%s]: Magic packet sent via broadcast to %s (%s:%d)\n", w.name, w.macAddress, broadcastAddr, w.port)
-			}
-		} else {
-			lastError = err
-			if w.debug {
-				fmt.Printf("WOL Plugin [%s]: Broadcast to %s failed: %v\n", w.name, broadcastAddr, err)
+// sshNegotiate picks the first client algorithm the server also supports
+func sshNegotiate(kind string, client, server []string) (string, error) {
+	for _, algorithm := range client {
+		for _, offered := range server {
+			if algorithm == offered {
+				return algorithm, nil
 			}
 		}
 	}
+	return "", fmt.Errorf("no common %s algorithm (server offers %s)", kind, strings.Join(server, ","))
+}
 
-	if !sentSuccessfully {
-		return fmt.Errorf("failed to send WOL packet to any address: %v", lastError)
+// sshExchangeHash computes the curve25519-sha256 exchange hash H (RFC 8731)
+func sshExchangeHash(clientVersion, serverVersion string, clientKexInit, serverKexInit, hostKey, clientPublic, serverPublic, secret []byte) []byte {
+	var data []byte
+	data = sshAppendString(data, []byte(clientVersion))
+	data = sshAppendString(data, []byte(serverVersion))
+	data = sshAppendString(data, clientKexInit)
+	data = sshAppendString(data, serverKexInit)
+	data = sshAppendString(data, hostKey)
+	data = sshAppendString(data, clientPublic)
+	data = sshAppendString(data, serverPublic)
+	data = sshAppendMpint(data, secret)
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// sshDeriveKey derives n bytes of key material for the given letter (RFC 4253 section 7.2)
+func sshDeriveKey(secret, exchangeHash, sessionID []byte, letter byte, n int) []byte {
+	var prefix []byte
+	prefix = sshAppendMpint(prefix, secret)
+	prefix = append(prefix, exchangeHash...)
+
+	digest := sha256.New()
+	digest.Write(prefix)
+	digest.Write([]byte{letter})
+	digest.Write(sessionID)
+	key := digest.Sum(nil)
+	for len(key) < n {
+		digest.Reset()
+		digest.Write(prefix)
+		digest.Write(key)
+		key = digest.Sum(key)
 	}
+	return key[:n]
+}
 
-	if w.debug {
-		fmt.Printf("WOL Plugin [%s]: Magic packet sent to %s\n", w.name, w.macAddress)
+func sshCipherKeySize(name string) int {
+	if name == "aes256-ctr" {
+		return 32
 	}
-	return nil
+	return 16
 }
 
-// sendToAddress sends WOL packet to a specific address
-func (w *WOLPlugin) sendToAddress(packet []byte, targetAddr string) error {
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", targetAddr, w.port))
-	if err != nil {
-		return fmt.Errorf("failed to resolve UDP address %s: %v", targetAddr, err)
+// sshVerifySignature checks a host key signature blob over data
+func sshVerifySignature(algorithm string, keyBlob, signatureBlob, data []byte) error {
+	key := &sshReader{data: keyBlob}
+	keyType := string(key.string())
+	sig := &sshReader{data: signatureBlob}
+	signatureType := string(sig.string())
+	signature := sig.string()
+	if key.err != nil || sig.err != nil {
+		return fmt.Errorf("malformed host key or signature")
+	}
+	if signatureType != algorithm {
+		return fmt.Errorf("signature type %q does not match negotiated %q", signatureType, algorithm)
 	}
 
-	conn, err := net.DialUDP("udp", nil, addr)
-	if err != nil {
-		return fmt.Errorf("failed to create UDP connection to %s: %v", targetAddr, err)
+	switch algorithm {
+	case "ssh-ed25519":
+		publicKey := key.string()
+		if keyType != algorithm || len(publicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("malformed ed25519 host key")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(publicKey), data, signature) {
+			return fmt.Errorf("ed25519 verification failed")
+		}
+		return nil
+	case "ecdsa-sha2-nistp256":
+		key.string() // curve name
+		x, y := elliptic.Unmarshal(elliptic.P256(), key.string())
+		if keyType != algorithm || x == nil {
+			return fmt.Errorf("malformed ecdsa host key")
+		}
+		inner := &sshReader{data: signature}
+		r, s := inner.mpint(), inner.mpint()
+		if inner.err != nil {
+			return fmt.Errorf("malformed ecdsa signature")
+		}
+		digest := sha256.Sum256(data)
+		if !ecdsa.Verify(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, digest[:], r, s) {
+			return fmt.Errorf("ecdsa verification failed")
+		}
+		return nil
+	case "rsa-sha2-256", "rsa-sha2-512":
+		e, n := key.mpint(), key.mpint()
+		if keyType != "ssh-rsa" || key.err != nil || !e.IsInt64() {
+			return fmt.Errorf("malformed rsa host key")
+		}
+		publicKey := &rsa.PublicKey{N: n, E: int(e.Int64())}
+		if algorithm == "rsa-sha2-256" {
+			digest := sha256.Sum256(data)
+			return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature)
+		}
+		digest := sha512.Sum512(data)
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA512, digest[:], signature)
 	}
-	defer conn.Close()
+	return fmt.Errorf("unsupported host key algorithm %q", algorithm)
+}
 
-	// Note: Broadcast is handled by OS defaults for UDP sockets
+// sshSigner signs authentication requests with a private key
+type sshSigner struct {
+	algorithm string
+	publicKey []byte // wire-format public key blob
+	sign      func(data []byte) ([]byte, error) // returns a wire-format signature blob
+}
 
-	_, err = conn.Write(packet)
-	if err != nil {
-		return fmt.Errorf("failed to send packet to %s: %v", targetAddr, err)
+// parseSSHPrivateKey parses an unencrypted PEM private key in OpenSSH, PKCS#1, SEC 1 or PKCS#8 format
+func parseSSHPrivateKey(pemBytes []byte) (*sshSigner, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found")
+	}
+	if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+		return nil, fmt.Errorf("encrypted private keys are not supported")
 	}
 
-	return nil
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "OPENSSH PRIVATE KEY":
+		key, err = parseOpenSSHPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSSHSigner(key)
 }
 
-func (w *WOLPlugin) parseMACAddress(macStr string) ([]byte, error) {
-	macStr = strings.ReplaceAll(macStr, ":", "")
-	macStr = strings.ReplaceAll(macStr, "-", "")
-	macStr = strings.ReplaceAll(macStr, ".", "")
-	macStr = strings.ToLower(macStr)
+// newSSHSigner wraps an ed25519, ecdsa P-256 or rsa private key
+func newSSHSigner(key interface{}) (*sshSigner, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		algorithm := "ssh-ed25519"
+		publicKey := sshAppendString(sshAppendString(nil, []byte(algorithm)), k.Public().(ed25519.PublicKey))
+		return &sshSigner{algorithm: algorithm, publicKey: publicKey, sign: func(data []byte) ([]byte, error) {
+			return sshAppendString(sshAppendString(nil, []byte(algorithm)), ed25519.Sign(k, data)), nil
+		}}, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported ecdsa curve %s: only P-256 is supported", k.Curve.Params().Name)
+		}
+		algorithm := "ecdsa-sha2-nistp256"
+		publicKey := sshAppendString(nil, []byte(algorithm))
+		publicKey = sshAppendString(publicKey, []byte("nistp256"))
+		publicKey = sshAppendString(publicKey, elliptic.Marshal(k.Curve, k.X, k.Y))
+		return &sshSigner{algorithm: algorithm, publicKey: publicKey, sign: func(data []byte) ([]byte, error) {
+			digest := sha256.Sum256(data)
+			r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+			if err != nil {
+				return nil, err
+			}
+			signature := sshAppendMpint(sshAppendMpint(nil, r.Bytes()), s.Bytes())
+			return sshAppendString(sshAppendString(nil, []byte(algorithm)), signature), nil
+		}}, nil
+	case *rsa.PrivateKey:
+		algorithm := "rsa-sha2-256"
+		publicKey := sshAppendString(nil, []byte("ssh-rsa"))
+		publicKey = sshAppendMpint(publicKey, big.NewInt(int64(k.E)).Bytes())
+		publicKey = sshAppendMpint(publicKey, k.N.Bytes())
+		return &sshSigner{algorithm: algorithm, publicKey: publicKey, sign: func(data []byte) ([]byte, error) {
+			digest := sha256.Sum256(data)
+			signature, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+			if err != nil {
+				return nil, err
+			}
+			return sshAppendString(sshAppendString(nil, []byte(algorithm)), signature), nil
+		}}, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", key)
+}
 
-	if len(macStr) != 12 {
-		return nil, fmt.Errorf("MAC address must be 12 hex characters")
+// parseOpenSSHPrivateKey decodes an unencrypted "openssh-key-v1" private key
+func parseOpenSSHPrivateKey(data []byte) (interface{}, error) {
+	const magic = "openssh-key-v1\x00"
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, fmt.Errorf("invalid OpenSSH private key")
+	}
+	r := &sshReader{data: data[len(magic):]}
+	cipherName := string(r.string())
+	r.string() // kdf name
+	r.string() // kdf options
+	if count := r.uint32(); r.err == nil && count != 1 {
+		return nil, fmt.Errorf("OpenSSH private key files with %d keys are not supported", count)
+	}
+	r.string() // public key
+	private := &sshReader{data: r.string()}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed OpenSSH private key")
+	}
+	if cipherName != "none" {
+		return nil, fmt.Errorf("encrypted private keys are not supported")
+	}
+	if private.uint32() != private.uint32() {
+		return nil, fmt.Errorf("malformed OpenSSH private key")
 	}
 
-	macBytes := make([]byte, 6)
-	for i := 0; i < 6; i++ {
-		b, err := strconv.ParseUint(macStr[i*2:i*2+2], 16, 8)
-		if err != nil {
-			return nil, fmt.Errorf("invalid hex in MAC address: %v", err)
+	var key interface{}
+	switch keyType := string(private.string()); keyType {
+	case "ssh-ed25519":
+		private.string() // public key
+		seed := private.string()
+		if len(seed) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("malformed ed25519 private key")
 		}
-		macBytes[i] = byte(b)
+		key = ed25519.PrivateKey(seed)
+	case "ecdsa-sha2-nistp256":
+		private.string() // curve name
+		x, y := elliptic.Unmarshal(elliptic.P256(), private.string())
+		d := private.mpint()
+		if x == nil {
+			return nil, fmt.Errorf("malformed ecdsa private key")
+		}
+		key = &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, D: d}
+	case "ssh-rsa":
+		n, e, d := private.mpint(), private.mpint(), private.mpint()
+		private.mpint() // iqmp
+		p, q := private.mpint(), private.mpint()
+		if private.err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("malformed rsa private key")
+		}
+		rsaKey := &rsa.PrivateKey{PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())}, D: d, Primes: []*big.Int{p, q}}
+		if err := rsaKey.Validate(); err != nil {
+			return nil, err
+		}
+		rsaKey.Precompute()
+		key = rsaKey
+	default:
+		return nil, fmt.Errorf("unsupported OpenSSH key type %q", keyType)
+	}
+	if private.err != nil {
+		return nil, fmt.Errorf("malformed OpenSSH private key")
 	}
+	return key, nil
+}
 
-	return macBytes, nil
+// sshAppendUint32 appends v in network byte order
+func sshAppendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
 }
 
-func (w *WOLPlugin) createMagicPacket(macBytes []byte) []byte {
-	packet := make([]byte, 102)
+// sshAppendString appends s as a length-prefixed SSH string
+func sshAppendString(b, s []byte) []byte {
+	return append(sshAppendUint32(b, uint32(len(s))), s...)
+}
 
-	for i := 0; i < 6; i++ {
-		packet[i] = 0xFF
+// sshAppendMpint appends the unsigned big-endian magnitude as an SSH mpint
+func sshAppendMpint(b, magnitude []byte) []byte {
+	for len(magnitude) > 0 && magnitude[0] == 0 {
+		magnitude = magnitude[1:]
 	}
-
-	for i := 0; i < 16; i++ {
-		copy(packet[6+i*6:], macBytes)
+	if len(magnitude) > 0 && magnitude[0]&0x80 != 0 {
+		magnitude = append([]byte{0}, magnitude...)
 	}
+	return sshAppendString(b, magnitude)
+}
 
-	return packet
+// sshReader decodes SSH wire types, recording the first error instead of returning one per field
+type sshReader struct {
+	data []byte
+	err  error
 }
 
-func (w *WOLPlugin) waitForService() bool {
-	if w.debug {
-		fmt.Printf("WOL Plugin [%s]: Waiting for service to come online (timeout: %v)\n", w.name, w.timeout)
+func (r *sshReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
 	}
-	
-	start := time.Now()
-	for time.Since(start) < w.timeout {
-		if w.performHealthCheck() {
-			return true
-		}
-		time.Sleep(2 * time.Second)
+	if n < 0 || n > len(r.data) {
+		r.err = fmt.Errorf("truncated SSH message")
+		return nil
 	}
-	return false
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
 }
 
-// serveControlPage renders and serves the control page
-func (w *WOLPlugin) serveControlPage(rw http.ResponseWriter, req *http.Request) {
-	tmpl, err := template.New("controlPage").Parse(controlPageTemplate)
-	if err != nil {
-		http.Error(rw, "Template error", http.StatusInternalServerError)
-		return
+func (r *sshReader) uint32() uint32 {
+	b := r.take(4)
+	if b == nil {
+		return 0
 	}
+	return binary.BigEndian.Uint32(b)
+}
 
-	data := struct {
-		Title                string
-		ServiceDescription   string
-		TimeoutSeconds       int
-		AutoRedirect         bool
-		RedirectDelaySeconds int
-		ConfirmPowerOff      bool
-		ShowPowerOffButton   bool
-		HideRedirectButton   bool
-	}{
-		Title:                w.controlPageTitle,
-		ServiceDescription:   w.serviceDescription,
-		TimeoutSeconds:       int(w.timeout.Seconds()),
-		AutoRedirect:         w.autoRedirect,
-		RedirectDelaySeconds: int(w.redirectDelay.Seconds()),
-		ConfirmPowerOff:      w.confirmPowerOff,
-		ShowPowerOffButton:   w.showPowerOffButton,
-		HideRedirectButton:   w.hideRedirectButton,
+func (r *sshReader) bool() bool {
+	b := r.take(1)
+	return b != nil && b[0] != 0
+}
+
+func (r *sshReader) string() []byte {
+	n := r.uint32()
+	if r.err != nil {
+		return nil
 	}
+	return r.take(int(n))
+}
 
-	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := tmpl.Execute(rw, data); err != nil {
-		http.Error(rw, "Template execution error", http.StatusInternalServerError)
-		return
+func (r *sshReader) mpint() *big.Int {
+	b := r.string()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		r.err = fmt.Errorf("negative SSH mpint")
 	}
+	return new(big.Int).SetBytes(b)
 }
 
-// handleWakeEndpoint handles POST requests to /_wol/wake
-func (w *WOLPlugin) handleWakeEndpoint(rw http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// Circuit breaker states
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// Circuit breaker defaults used when only circuitBreakerThreshold is configured
+const (
+	defaultCircuitBreakerWindow   = 10 * time.Minute
+	defaultCircuitBreakerCooldown = 5 * time.Minute
+)
+
+// allowWakeAttempt reports whether a wake sequence may start, or how long until the open circuit breaker
+// allows a trial. Once the cooldown has passed the breaker half-opens and lets a single trial wake through.
+func (w *WOLPlugin) allowWakeAttempt() (time.Duration, bool) {
+	if w.circuitBreakerThreshold <= 0 {
+		return 0, true
 	}
 
-	w.wakeMutex.Lock()
-	if w.wakeCache.isWaking || w.wakeCache.isPoweringOff {
-		processType := "wake"
-		if w.wakeCache.isPoweringOff {
-			processType = "power-off"
+	w.breakerMutex.Lock()
+	defer w.breakerMutex.Unlock()
+
+	switch w.breakerState {
+	case breakerOpen:
+		remaining := w.breakerOpenedAt.Add(w.circuitBreakerCooldown).Sub(w.now())
+		if remaining > 0 {
+			return remaining, false
 		}
-		w.wakeMutex.Unlock()
-		w.writeJSONResponse(rw, map[string]interface{}{
-			"success": false,
-			"message": fmt.Sprintf("%s process already in progress", processType),
-		})
+		fmt.Printf("WOL Plugin [%s]: Circuit breaker half-open, allowing a trial wake\n", w.name)
+		w.breakerState = breakerHalfOpen
+		return 0, true
+	case breakerHalfOpen:
+		// The trial wake is still running
+		return w.timeout, false
+	}
+	return 0, true
+}
+
+// recordWakeResult updates the circuit breaker with the outcome of a wake sequence. Sequences stopped by
+// ctx are not counted as failures.
+func (w *WOLPlugin) recordWakeResult(ctx context.Context, success bool) {
+	if w.circuitBreakerThreshold <= 0 {
+		return
+	}
+	if ctx.Err() != nil {
+		w.abandonWakeAttempt()
 		return
 	}
 
-	w.wakeCache.isWaking = true
-	w.wakeCache.isPoweringOff = false
-	w.wakeCache.startTime = time.Now()
-	w.wakeCache.message = "Initiating wake sequence..."
-	w.wakeCache.progress = 0
-	w.wakeMutex.Unlock()
-
-	// Start wake process in background
-	go w.performWakeSequence()
+	w.breakerMutex.Lock()
+	defer w.breakerMutex.Unlock()
 
-	w.writeJSONResponse(rw, map[string]interface{}{
-		"success": true,
-		"message": "Wake process started",
-	})
-}
+	now := w.now()
+	if success {
+		if w.breakerState != breakerClosed {
+			fmt.Printf("WOL Plugin [%s]: Trial wake succeeded, circuit breaker closed\n", w.name)
+		}
+		w.breakerState = breakerClosed
+		w.breakerFailures = 0
+		return
+	}
 
-// handleStatusEndpoint handles GET requests to /_wol/status
-func (w *WOLPlugin) handleStatusEndpoint(rw http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodGet {
-		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+	if w.breakerState == breakerHalfOpen {
+		fmt.Printf("WOL Plugin [%s]: Trial wake failed, suspending wakes for %v\n", w.name, w.circuitBreakerCooldown)
+		w.breakerState = breakerOpen
+		w.breakerOpenedAt = now
 		return
 	}
 
-	isHealthy := w.getCachedHealthStatus()
-	
-	w.wakeMutex.RLock()
-	wakeStatus := *w.wakeCache
-	w.wakeMutex.RUnlock()
+	// Failures only count as consecutive while they fall within the window
+	if w.breakerFailures == 0 || now.Sub(w.breakerFirstFailure) > w.circuitBreakerWindow {
+		w.breakerFailures = 0
+		w.breakerFirstFailure = now
+	}
+	w.breakerFailures++
+	if w.breakerFailures >= w.circuitBreakerThreshold {
+		fmt.Printf("WOL Plugin [%s]: Circuit breaker opened after %d consecutive failed wakes, suspending wakes for %v\n", w.name, w.breakerFailures, w.circuitBreakerCooldown)
+		w.breakerState = breakerOpen
+		w.breakerOpenedAt = now
+		w.breakerFailures = 0
+	}
+}
+
+// abandonWakeAttempt releases a half-open trial that ended without a result, so the next request retries
+func (w *WOLPlugin) abandonWakeAttempt() {
+	w.breakerMutex.Lock()
+	defer w.breakerMutex.Unlock()
 
-	response := map[string]interface{}{
-		"isHealthy":     isHealthy,
-		"isWaking":      wakeStatus.isWaking,
-		"isPoweringOff": wakeStatus.isPoweringOff,
-		"message":       wakeStatus.message,
-		"progress":      wakeStatus.progress,
+	if w.breakerState == breakerHalfOpen {
+		w.breakerState = breakerOpen
 	}
+}
 
-	w.writeJSONResponse(rw, response)
+// retryAfterSeconds formats d for a Retry-After header, rounding up to whole seconds
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int((d + time.Second - 1) / time.Second))
 }
 
+// Tracing follows the W3C Trace Context propagation format and the OpenTelemetry span model without the
+// OpenTelemetry SDK, which Yaegi cannot load. Spans are exported as OTLP/HTTP JSON or written to the log.
 
+// Span kinds as defined by OTLP
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+)
 
-// performAutoWake handles the legacy auto-wake behavior when control page is disabled
-func (w *WOLPlugin) performAutoWake(rw http.ResponseWriter, req *http.Request) {
-	fmt.Printf("WOL Plugin [%s]: Service unhealthy, attempting to wake %s\n", w.name, w.macAddress)
-	
-	success := false
-	for attempt := 1; attempt <= w.retryAttempts; attempt++ {
-		if w.debug {
-			fmt.Printf("WOL Plugin [%s]: Wake attempt %d/%d\n", w.name, attempt, w.retryAttempts)
-		}
+// spanContext identifies a span for propagation between services
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	flags   byte
+}
 
-		if err := w.sendWOLPacket(); err != nil {
-			fmt.Printf("WOL Plugin [%s]: Failed to send WOL packet (attempt %d): %v\n", w.name, attempt, err)
-			if attempt < w.retryAttempts {
-				time.Sleep(w.retryInterval)
-				continue
-			}
-			http.Error(rw, "Failed to wake up service after all attempts", http.StatusServiceUnavailable)
-			return
-		}
+// traceparent formats the context as a W3C traceparent header value
+func (c spanContext) traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(c.traceID[:]), hex.EncodeToString(c.spanID[:]), c.flags)
+}
 
-		if w.waitForService() {
-			success = true
-			break
+// parseTraceparent parses a W3C traceparent header value, reporting false when it is missing or malformed
+func parseTraceparent(value string) (spanContext, bool) {
+	var c spanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return c, false
+	}
+	for i, size := range []int{1, 16, 8, 1} {
+		if len(parts[i]) != size*2 || parts[i] != strings.ToLower(parts[i]) {
+			return c, false
 		}
+	}
 
-		if attempt < w.retryAttempts {
-			fmt.Printf("WOL Plugin [%s]: Service not responding, retrying in %v\n", w.name, w.retryInterval)
-			time.Sleep(w.retryInterval)
-		}
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return c, false
+	}
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return c, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return c, false
+	}
+	if _, err := hex.DecodeString(parts[0]); err != nil {
+		return c, false
 	}
 
-	if !success {
-		fmt.Printf("WOL Plugin [%s]: Service did not come online after %d attempts\n", w.name, w.retryAttempts)
-		http.Error(rw, "Service did not respond after wake up attempts", http.StatusServiceUnavailable)
-		return
+	copy(c.traceID[:], traceID)
+	copy(c.spanID[:], spanID)
+	c.flags = flags[0]
+	if c.traceID == [16]byte{} || c.spanID == [8]byte{} {
+		return c, false
 	}
+	return c, true
+}
 
-	fmt.Printf("WOL Plugin [%s]: Service is now online\n", w.name)
-	w.next.ServeHTTP(rw, req)
+// traceSpan is a timed operation within a trace. Methods on a nil *traceSpan do nothing, so call sites
+// need no checks when tracing is disabled.
+type traceSpan struct {
+	context    spanContext
+	parentID   [8]byte // zero for a root span without an incoming traceparent
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	errMessage string
+	plugin     *WOLPlugin
 }
 
-// writeJSONResponse writes a JSON response
-func (w *WOLPlugin) writeJSONResponse(rw http.ResponseWriter, data interface{}) {
-	rw.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(rw).Encode(data); err != nil {
-		http.Error(rw, "JSON encoding error", http.StatusInternalServerError)
+// startSpan starts a server span for req, continuing the caller's trace when it sends a traceparent header.
+// It returns nil when tracing is disabled.
+func (w *WOLPlugin) startSpan(name string, req *http.Request) *traceSpan {
+	if !w.enableTracing {
+		return nil
+	}
+
+	span := &traceSpan{name: name, kind: spanKindServer, start: w.now(), attributes: map[string]interface{}{}, plugin: w}
+	if parent, ok := parseTraceparent(traceparentHeader(req)); ok {
+		span.context.traceID = parent.traceID
+		span.context.flags = parent.flags
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.context.traceID[:])
+		span.context.flags = 0x01 // sampled
 	}
+	rand.Read(span.context.spanID[:])
+	return span
 }
 
-// performWakeSequence runs the wake sequence with status updates
-func (w *WOLPlugin) performWakeSequence() {
-	defer func() {
-		w.wakeMutex.Lock()
-		w.wakeCache.isWaking = false
-		w.wakeMutex.Unlock()
-	}()
+// traceparentHeader returns the request's traceparent header; spans for Go API calls have no request
+func traceparentHeader(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	return req.Header.Get("traceparent")
+}
 
-	fmt.Printf("WOL Plugin [%s]: Service unhealthy, attempting to wake %s\n", w.name, w.macAddress)
+// startChild starts an internal span under s
+func (s *traceSpan) startChild(name string) *traceSpan {
+	if s == nil {
+		return nil
+	}
+	child := &traceSpan{
+		context:    spanContext{traceID: s.context.traceID, flags: s.context.flags},
+		parentID:   s.context.spanID,
+		name:       name,
+		kind:       spanKindInternal,
+		start:      s.plugin.now(),
+		attributes: map[string]interface{}{},
+		plugin:     s.plugin,
+	}
+	rand.Read(child.context.spanID[:])
+	return child
+}
 
-	for attempt := 1; attempt <= w.retryAttempts; attempt++ {
-		w.wakeMutex.Lock()
-		w.wakeCache.message = fmt.Sprintf("Wake attempt %d/%d - Sending WOL packet...", attempt, w.retryAttempts)
-		w.wakeCache.progress = int(float64(attempt-1) / float64(w.retryAttempts) * 40) // 0-40% for sending packets
-		w.wakeMutex.Unlock()
+func (s *traceSpan) setAttribute(key string, value interface{}) {
+	if s != nil {
+		s.attributes[key] = value
+	}
+}
 
-		if w.debug {
-			fmt.Printf("WOL Plugin [%s]: Wake attempt %d/%d\n", w.name, attempt, w.retryAttempts)
-		}
+// setError marks the span as failed with message
+func (s *traceSpan) setError(message string) {
+	if s != nil {
+		s.errMessage = message
+	}
+}
 
-		if err := w.sendWOLPacket(); err != nil {
-			fmt.Printf("WOL Plugin [%s]: Failed to send WOL packet (attempt %d): %v\n", w.name, attempt, err)
-			w.wakeMutex.Lock()
-			w.wakeCache.message = fmt.Sprintf("Failed to send WOL packet (attempt %d): %v", attempt, err)
-			w.wakeMutex.Unlock()
-			
-			if attempt < w.retryAttempts {
-				time.Sleep(w.retryInterval)
-				continue
-			}
-			
-			w.wakeMutex.Lock()
-			w.wakeCache.message = "Failed to wake up service after all attempts"
-			w.wakeMutex.Unlock()
-			return
-		}
+// inject sets the traceparent header so a downstream service continues the trace under s
+func (s *traceSpan) inject(header http.Header) {
+	if s != nil {
+		header.Set("traceparent", s.context.traceparent())
+	}
+}
 
-		w.wakeMutex.Lock()
-		w.wakeCache.message = fmt.Sprintf("WOL packet sent (attempt %d/%d) - Waiting for service...", attempt, w.retryAttempts)
-		w.wakeCache.progress = 40 + int(float64(attempt-1) / float64(w.retryAttempts) * 30) // 40-70% for waiting
-		w.wakeMutex.Unlock()
+// finish ends the span and hands it to the exporter
+func (s *traceSpan) finish() {
+	if s == nil {
+		return
+	}
+	s.end = s.plugin.now()
+	s.plugin.spanExporter.ExportSpan(s)
+}
 
-		if w.waitForServiceWithProgress() {
-			w.wakeMutex.Lock()
-			w.wakeCache.message = "Service is now online!"
-			w.wakeCache.progress = 100
-			w.wakeMutex.Unlock()
-			fmt.Printf("WOL Plugin [%s]: Service is now online\n", w.name)
-			return
-		}
+// sendWOLPacketTraced sends the magic packet, recording the send as a child span of parent
+func (w *WOLPlugin) sendWOLPacketTraced(parent *traceSpan, attempt int) error {
+	span := parent.startChild("wol.send_packet")
+	span.setAttribute("wol.mac", w.macAddress)
+	span.setAttribute("wol.attempt", attempt)
+	err := w.sendWOLPacket()
+	if err != nil {
+		span.setError(err.Error())
+	}
+	span.finish()
+	return err
+}
 
-		if attempt < w.retryAttempts {
-			fmt.Printf("WOL Plugin [%s]: Service not responding, retrying in %v\n", w.name, w.retryInterval)
-			w.wakeMutex.Lock()
-			w.wakeCache.message = fmt.Sprintf("Service not responding, retrying in %v", w.retryInterval)
-			w.wakeMutex.Unlock()
-			time.Sleep(w.retryInterval)
-		}
+type spanContextKey struct{}
+
+// contextWithSpan carries span into a background operation
+func contextWithSpan(ctx context.Context, span *traceSpan) context.Context {
+	if span == nil {
+		return ctx
 	}
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
 
-	fmt.Printf("WOL Plugin [%s]: Service did not come online after %d attempts\n", w.name, w.retryAttempts)
-	w.wakeMutex.Lock()
-	w.wakeCache.message = fmt.Sprintf("Service did not come online after %d attempts", w.retryAttempts)
-	w.wakeMutex.Unlock()
+// spanFromContext returns the span carried by ctx, or nil
+func spanFromContext(ctx context.Context) *traceSpan {
+	span, _ := ctx.Value(spanContextKey{}).(*traceSpan)
+	return span
 }
 
-// waitForServiceWithProgress waits for service with progress updates
-func (w *WOLPlugin) waitForServiceWithProgress() bool {
-	if w.debug {
-		fmt.Printf("WOL Plugin [%s]: Waiting for service to come online (timeout: %v)\n", w.name, w.timeout)
-	}
-	
-	start := time.Now()
-	checkInterval := 2 * time.Second
-	
-	for time.Since(start) < w.timeout {
-		if w.performHealthCheck() {
-			return true
-		}
-		
-		// Update progress during wait
-		elapsed := time.Since(start)
-		progress := 70 + int(float64(elapsed)/float64(w.timeout)*30) // 70-100% for waiting
-		if progress > 95 {
-			progress = 95 // Cap at 95% until actually healthy
-		}
-		
-		w.wakeMutex.Lock()
-		w.wakeCache.progress = progress
-		remaining := w.timeout - elapsed
-		w.wakeCache.message = fmt.Sprintf("Waiting for service... (%v remaining)", remaining.Truncate(time.Second))
-		w.wakeMutex.Unlock()
-		
-		time.Sleep(checkInterval)
+// spanExporter receives finished spans; implementations must not block the caller
+type spanExporter interface {
+	ExportSpan(span *traceSpan)
+}
+
+// logSpanExporter writes finished spans to the log when no tracingEndpoint is configured
+type logSpanExporter struct {
+	name string
+}
+
+func (e *logSpanExporter) ExportSpan(span *traceSpan) {
+	status := "ok"
+	if span.errMessage != "" {
+		status = "error: " + span.errMessage
 	}
-	return false
+	fmt.Printf("WOL Plugin [%s]: Span %s trace=%s span=%s duration=%v attributes=%v status=%s\n",
+		e.name, span.name, hex.EncodeToString(span.context.traceID[:]), hex.EncodeToString(span.context.spanID[:]),
+		span.end.Sub(span.start), span.attributes, status)
 }
 
-// handleRedirectEndpoint handles POST requests to /_wol/redirect
-func (w *WOLPlugin) handleRedirectEndpoint(rw http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// parseTracingEndpoint normalizes an OTLP/HTTP collector URL such as "http://otel-collector:4318" to its
+// traces endpoint
+func parseTracingEndpoint(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid tracingEndpoint %q: %v", endpoint, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid tracingEndpoint %q: expected an http(s) URL", endpoint)
 	}
+	if !strings.HasSuffix(parsed.Path, "/v1/traces") {
+		parsed.Path = strings.TrimSuffix(parsed.Path, "/") + "/v1/traces"
+	}
+	return parsed.String(), nil
+}
 
-	// Set bypass state with 5-second expiration
-	w.bypassMutex.Lock()
-	w.bypassCache.isBypass = true
-	w.bypassCache.startTime = time.Now()
-	w.bypassMutex.Unlock()
+// spanQueueSize bounds the spans waiting to be exported; newer spans are dropped when it is full
+const spanQueueSize = 256
 
-	if w.debug {
-		fmt.Printf("WOL Plugin [%s]: Redirect request received, bypass state set\n", w.name)
-	}
+// otlpExporter posts spans to an OpenTelemetry collector using OTLP/HTTP with JSON encoding
+type otlpExporter struct {
+	name     string
+	endpoint string
+	client   *http.Client
+	spans    chan *traceSpan
+}
 
-	// Redirect to current path without any parameters
-	redirectURL := req.URL.Path
-	if redirectURL == "/_wol/redirect" {
-		redirectURL = "/"
+func newOTLPExporter(name, endpoint string) *otlpExporter {
+	return &otlpExporter{
+		name:     name,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		spans:    make(chan *traceSpan, spanQueueSize),
 	}
-	
-	http.Redirect(rw, req, redirectURL, http.StatusFound)
 }
 
-// handlePowerOffEndpoint handles POST requests to /_wol/poweroff
-func (w *WOLPlugin) handlePowerOffEndpoint(rw http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// ExportSpan queues span for the next batch
+func (e *otlpExporter) ExportSpan(span *traceSpan) {
+	select {
+	case e.spans <- span:
+	default:
+		fmt.Printf("WOL Plugin [%s]: Span queue full, dropping span %s\n", e.name, span.name)
 	}
+}
 
-	w.wakeMutex.Lock()
-	if w.wakeCache.isWaking || w.wakeCache.isPoweringOff {
-		processType := "power-off"
-		if w.wakeCache.isWaking {
-			processType = "wake"
+// run posts queued spans in batches until ctx is done
+func (e *otlpExporter) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case span := <-e.spans:
+			batch := []*traceSpan{span}
+		drain:
+			for len(batch) < spanQueueSize {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+				default:
+					break drain
+				}
+			}
+			// A batch cut short by shutdown is not worth reporting
+			if err := e.export(ctx, batch); err != nil && ctx.Err() == nil {
+				fmt.Printf("WOL Plugin [%s]: Failed to export %d spans: %v\n", e.name, len(batch), err)
+			}
 		}
-		w.wakeMutex.Unlock()
-		w.writeJSONResponse(rw, map[string]interface{}{
-			"success": false,
-			"message": fmt.Sprintf("%s process already in progress", processType),
-		})
-		return
 	}
+}
 
-	w.wakeCache.isPoweringOff = true
-	w.wakeCache.isWaking = false
-	w.wakeCache.startTime = time.Now()
-	w.wakeCache.message = "Initiating power-off sequence..."
-	w.wakeCache.progress = 0
-	w.wakeMutex.Unlock()
-
-	// Start power-off process in background
-	go w.performPowerOffSequence()
+// export posts one batch of spans
+func (e *otlpExporter) export(ctx context.Context, spans []*traceSpan) error {
+	body, err := json.Marshal(otlpTraceRequest(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-	w.writeJSONResponse(rw, map[string]interface{}{
-		"success": true,
-		"message": "Power-off process started",
-	})
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
 }
 
-// performPowerOffSequence executes the power-off command based on the configured method
-func (w *WOLPlugin) performPowerOffSequence() {
-	defer func() {
-		w.wakeMutex.Lock()
-		w.wakeCache.isPoweringOff = false
-		w.wakeMutex.Unlock()
-	}()
-
-	fmt.Printf("WOL Plugin [%s]: Starting power-off sequence using custom script: %s\n", w.name, w.powerOffCommand)
+// otlpTraceRequest builds an OTLP ExportTraceServiceRequest in its JSON mapping
+func otlpTraceRequest(spans []*traceSpan) map[string]interface{} {
+	encoded := make([]map[string]interface{}, 0, len(spans))
+	for _, span := range spans {
+		var attributes []map[string]interface{}
+		for key, value := range span.attributes {
+			attributes = append(attributes, map[string]interface{}{"key": key, "value": otlpValue(value)})
+		}
+		status := map[string]interface{}{"code": 1} // STATUS_CODE_OK
+		if span.errMessage != "" {
+			status = map[string]interface{}{"code": 2, "message": span.errMessage} // STATUS_CODE_ERROR
+		}
 
-	w.wakeMutex.Lock()
-	w.wakeCache.message = "Power-off requires external script execution..."
-	w.wakeCache.progress = 50
-	w.wakeMutex.Unlock()
+		encodedSpan := map[string]interface{}{
+			"traceId":           hex.EncodeToString(span.context.traceID[:]),
+			"spanId":            hex.EncodeToString(span.context.spanID[:]),
+			"name":              span.name,
+			"kind":              span.kind,
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.end.UnixNano(), 10),
+			"attributes":        attributes,
+			"status":            status,
+		}
+		if span.parentID != [8]byte{} {
+			encodedSpan["parentSpanId"] = hex.EncodeToString(span.parentID[:])
+		}
+		encoded = append(encoded, encodedSpan)
+	}
 
-	// Note: Since os/exec is not available in Yaegi, we cannot execute the script directly.
-	// The user must ensure their custom script is executed externally (e.g., via webhook, API call, etc.)
-	fmt.Printf("WOL Plugin [%s]: Power-off command configured: %s\n", w.name, w.powerOffCommand)
-	fmt.Printf("WOL Plugin [%s]: Note - Custom script must be executed externally as os/exec is not available in Yaegi\n", w.name)
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []interface{}{map[string]interface{}{"key": "service.name", "value": otlpValue("traefik-power-management")}},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "traefik-power-management", "version": PluginVersion},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
 
-	w.wakeMutex.Lock()
-	w.wakeCache.message = "Power-off command executed successfully"
-	w.wakeCache.progress = 100
-	w.wakeMutex.Unlock()
+// otlpValue wraps an attribute value in its OTLP AnyValue JSON form
+func otlpValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(value)}
+}
 
-	// Give some time for the service to actually go down
-	time.Sleep(5 * time.Second)
+// Actions recorded in the audit log
+const (
+	auditActionWake     = "wake"
+	auditActionPowerOff = "power_off"
+)
 
-	fmt.Printf("WOL Plugin [%s]: Power-off sequence completed\n", w.name)
+// auditRecord is one line of the audit log
+type auditRecord struct {
+	Time     string `json:"time"`
+	Name     string `json:"name"`
+	Action   string `json:"action"`
+	ClientIP string `json:"clientIp"`
+	User     string `json:"user,omitempty"`
+	Forced   bool   `json:"forced,omitempty"`
+	Outcome  string `json:"outcome"`
+	Message  string `json:"message,omitempty"`
 }
 
+// audit records who asked for a wake or power-off and whether it started. The user is the Basic Auth
+// username when the request still carries one. Records are appended to auditLogPath as JSON lines, or
+// printed to stdout when no path is configured.
+func (w *WOLPlugin) audit(req *http.Request, action string, err error) {
+	record := auditRecord{
+		Time:     w.now().UTC().Format(time.RFC3339),
+		Name:     w.name,
+		Action:   action,
+		ClientIP: w.clientIP(req).String(),
+		Outcome:  "started",
+	}
+	if user, _, ok := req.BasicAuth(); ok {
+		record.User = user
+	}
+	if action == auditActionWake {
+		record.Forced, _ = strconv.ParseBool(req.FormValue("force"))
+	}
+	if err != nil {
+		record.Outcome = codeSendFailed
+		if opErr, ok := err.(*operationError); ok {
+			record.Outcome = opErr.code
+		}
+		record.Message = err.Error()
+	}
+
+	line, _ := json.Marshal(record)
+
+	w.auditMutex.Lock()
+	defer w.auditMutex.Unlock()
+	if w.auditLogPath == "" {
+		fmt.Printf("WOL Plugin [%s]: audit %s\n", w.name, line)
+		return
+	}
+	file, openErr := os.OpenFile(w.auditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if openErr != nil {
+		fmt.Printf("WOL Plugin [%s]: Failed to open audit log %s: %v\n", w.name, w.auditLogPath, openErr)
+		return
+	}
+	defer file.Close()
+	if _, writeErr := file.Write(append(line, '\n')); writeErr != nil {
+		fmt.Printf("WOL Plugin [%s]: Failed to write audit log %s: %v\n", w.name, w.auditLogPath, writeErr)
+	}
+}